@@ -0,0 +1,151 @@
+package horenso
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// condition decides whether a handler should be run for the report
+type condition struct {
+	expr  string
+	match func(Report) bool
+}
+
+// conditions are satisfied when any of them matches. Empty conditions always match.
+type conditions []condition
+
+var condInReg = regexp.MustCompile(`^(exitCode|severity)\s+in\s+\[(.*)\]$`)
+
+func parseCondition(expr string) (condition, error) {
+	expr = strings.TrimSpace(expr)
+	cond := condition{expr: expr}
+	switch expr {
+	case "always":
+		cond.match = func(Report) bool { return true }
+	case statusSuccess, statusFailure, statusSkipped:
+		cond.match = func(r Report) bool { return r.Status == expr }
+	case "signaled":
+		cond.match = func(r Report) bool { return r.Signaled }
+	case "timeout":
+		cond.match = func(r Report) bool { return r.TimedOut }
+	case "change":
+		cond.match = stateChanged
+	default:
+		m := condInReg.FindStringSubmatch(expr)
+		if m == nil {
+			return cond, fmt.Errorf("invalid condition: %q", expr)
+		}
+		var values []string
+		for _, v := range strings.Split(m[2], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		switch m[1] {
+		case "exitCode":
+			codes := make(map[int]bool, len(values))
+			for _, v := range values {
+				code, err := strconv.Atoi(v)
+				if err != nil {
+					return cond, fmt.Errorf("invalid exit code %q in condition: %q", v, expr)
+				}
+				codes[code] = true
+			}
+			cond.match = func(r Report) bool { return r.EndAt != nil && codes[r.ExitCode] }
+		case "severity":
+			sevs := make(map[string]bool, len(values))
+			for _, v := range values {
+				sevs[v] = true
+			}
+			cond.match = func(r Report) bool { return sevs[r.Severity] }
+		}
+	}
+	return cond, nil
+}
+
+func (conds conditions) match(r Report) bool {
+	if len(conds) == 0 {
+		return true
+	}
+	for _, c := range conds {
+		if c.match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (conds conditions) String() string {
+	exprs := make([]string, len(conds))
+	for i, c := range conds {
+		exprs[i] = c.expr
+	}
+	return strings.Join(exprs, ", ")
+}
+
+func (conds conditions) MarshalYAML() (interface{}, error) {
+	exprs := make([]string, len(conds))
+	for i, c := range conds {
+		exprs[i] = c.expr
+	}
+	return exprs, nil
+}
+
+func (conds *conditions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var aux interface{}
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+	var exprs []string
+	switch raw := aux.(type) {
+	case string:
+		exprs = []string{raw}
+	case []interface{}:
+		for _, r := range raw {
+			v, ok := r.(string)
+			if !ok {
+				return fmt.Errorf("conditions should be a string or an array of string: %v", aux)
+			}
+			exprs = append(exprs, v)
+		}
+	default:
+		return fmt.Errorf("conditions should be a string or an array of string: %v", aux)
+	}
+	list := make(conditions, len(exprs))
+	for i, expr := range exprs {
+		c, err := parseCondition(expr)
+		if err != nil {
+			return err
+		}
+		list[i] = c
+	}
+	*conds = list
+	return nil
+}
+
+func (conds conditions) has(expr string) bool {
+	for _, c := range conds {
+		if c.expr == expr {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChangeCondition checks that the state directory is specified for the handlers with the
+// "change" condition, which would be satisfied on every failure without the previous state
+func checkChangeCondition(stateDir string, hss ...handlers) error {
+	if stateDir != "" {
+		return nil
+	}
+	for _, hs := range hss {
+		for _, h := range hs {
+			if h.On.has("change") {
+				return fmt.Errorf("the condition \"change\" of the handler %q requires the state directory", h.name())
+			}
+		}
+	}
+	return nil
+}
@@ -2,20 +2,139 @@ package horenso
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
-type handlers []string
+// handler is a command to be run with the report and conditions to run it.
+// The report is passed in JSON or rendered with Format via STDIN.
+// The commands starting with "webhook+", "slack+", "mail+", "syslog" or
+// "journald" are built-in reporters.
+type handler struct {
+	Command       string        `yaml:"command" json:"command"`
+	On            conditions    `yaml:"on,omitempty" json:"-"`
+	Timeout       time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retry         int           `yaml:"retry,omitempty" json:"-"`
+	RetryInterval time.Duration `yaml:"retryInterval,omitempty" json:"-"`
+	Format        string        `yaml:"format,omitempty" json:"format,omitempty"`
+
+	// options for the webhook reporter
+	Headers    map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	SecretFile string            `yaml:"secretFile,omitempty" json:"secretFile,omitempty"`
+	SecretEnv  string            `yaml:"secretEnv,omitempty" json:"secretEnv,omitempty"`
+
+	// options for the slack reporter
+	Channel   string `yaml:"channel,omitempty" json:"channel,omitempty"`
+	Username  string `yaml:"username,omitempty" json:"username,omitempty"`
+	IconEmoji string `yaml:"iconEmoji,omitempty" json:"iconEmoji,omitempty"`
+	Title     string `yaml:"title,omitempty" json:"title,omitempty"`
+	Text      string `yaml:"text,omitempty" json:"text,omitempty"`
+
+	mailOptions   `yaml:",inline"`
+	syslogOptions `yaml:",inline"`
+
+	// Expand is set for the handlers in the config files whose references are expanded on
+	// running them. It is kept in the spool entries.
+	Expand bool `yaml:"-" json:"expand,omitempty"`
+
+	// the command before expanding the references, which is shown in logs
+	rawCommand string
+}
+
+// name returns the command of the handler to be shown in logs without expanded secrets
+func (h handler) name() string {
+	if h.rawCommand != "" {
+		return h.rawCommand
+	}
+	return h.Command
+}
+
+type handlers []handler
+
+func newHandlers(cmds []string) handlers {
+	hs := make(handlers, len(cmds))
+	for i, cmd := range cmds {
+		hs[i] = handler{Command: cmd}
+	}
+	return hs
+}
+
+func (ha handlers) commands() []string {
+	cmds := make([]string, len(ha))
+	for i, h := range ha {
+		cmds[i] = h.Command
+	}
+	return cmds
+}
 
 type config struct {
-	Reporter       handlers `yaml:"reporter"`
-	Noticer        handlers `yaml:"noticer"`
-	Timestamp      bool     `yaml:"timestamp"`
-	Tag            string   `yaml:"tag"`
-	OverrideStatus bool     `yaml:"overrideStatus"`
-	Logfile        string   `yaml:"log"`
+	Reporter         handlers       `yaml:"reporter"`
+	Noticer          handlers       `yaml:"noticer"`
+	Timestamp        *bool          `yaml:"timestamp"`
+	Tag              string         `yaml:"tag"`
+	OverrideStatus   *bool          `yaml:"overrideStatus"`
+	Verbose          int            `yaml:"verbose"`
+	Logfile          string         `yaml:"log"`
+	StrictConfig     bool           `yaml:"strictConfig"`
+	Timeout          time.Duration  `yaml:"timeout"`
+	KillAfter        time.Duration  `yaml:"killAfter"`
+	Lock             string         `yaml:"lock"`
+	LockPolicy       string         `yaml:"lockPolicy"`
+	LockWait         time.Duration  `yaml:"lockWait"`
+	MaxOutput        outputLimit    `yaml:"maxOutput"`
+	SpillOutput      *bool          `yaml:"spillOutput"`
+	KeepOutput       *bool          `yaml:"keepOutput"`
+	HandlerTimeout   time.Duration  `yaml:"handlerTimeout"`
+	HandlerRetry     int            `yaml:"handlerRetry"`
+	SpoolDir         string         `yaml:"spoolDir"`
+	Format           string         `yaml:"format"`
+	PrintReport      string         `yaml:"printReport"`
+	StateDir         string         `yaml:"stateDir"`
+	HistoryDir       string         `yaml:"historyDir"`
+	HistoryRetention int            `yaml:"historyRetention"`
+	SuccessExitCodes []int          `yaml:"successExitCodes"`
+	Severity         map[int]string `yaml:"severity"`
+	Include          []string       `yaml:"include,omitempty"`
+
+	Defaults *config            `yaml:"defaults,omitempty"`
+	Jobs     map[string]*config `yaml:"jobs,omitempty"`
+}
+
+// profile returns the config for the job of the tag. The defaults and the profile of the
+// tag in jobs are merged into the top-level values in this order.
+func (c *config) profile(tag string) *config {
+	top := *c
+	top.Defaults, top.Jobs = nil, nil
+	p := &config{}
+	mergeConfig(p, &top)
+	if c.Defaults != nil {
+		mergeConfig(p, c.Defaults)
+	}
+	if j := c.Jobs[tag]; j != nil {
+		mergeConfig(p, j)
+	}
+	return p
+}
+
+func (c *config) validateProfiles() error {
+	profiles := map[string]*config{"defaults": c.Defaults}
+	for tag, j := range c.Jobs {
+		profiles[fmt.Sprintf("jobs.%s", tag)] = j
+	}
+	for name, p := range profiles {
+		if p != nil && (p.Defaults != nil || p.Jobs != nil || len(p.Include) > 0) {
+			return fmt.Errorf("defaults, jobs and include are not allowed in %q", name)
+		}
+	}
+	return nil
 }
 
 func (ha *handlers) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -23,32 +142,261 @@ func (ha *handlers) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&aux); err != nil {
 		return err
 	}
-	switch raw := aux.(type) {
-	case string:
-		*ha = []string{raw}
+	switch aux.(type) {
 	case []interface{}:
-		list := make([]string, len(raw))
-		for i, r := range raw {
-			v, ok := r.(string)
-			if !ok {
-				return fmt.Errorf("handlers should be a string or an array of string: %v", aux)
-			}
-			list[i] = v
+		var list []handler
+		if err := unmarshal(&list); err != nil {
+			return err
 		}
 		*ha = list
 	default:
-		return fmt.Errorf("handlers should be a string or an array of string: %v", aux)
+		var h handler
+		if err := unmarshal(&h); err != nil {
+			return err
+		}
+		*ha = handlers{h}
 	}
 	return nil
 }
 
+func (h *handler) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var aux interface{}
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+	switch v := aux.(type) {
+	case string:
+		*h = handler{Command: v}
+	case map[interface{}]interface{}:
+		type rawHandler handler
+		var raw rawHandler
+		if err := unmarshal(&raw); err != nil {
+			return err
+		}
+		if raw.Command == "" {
+			return fmt.Errorf("handler should have a command: %v", aux)
+		}
+		for _, tmpl := range []string{raw.Format, raw.Title, raw.Text, raw.Subject} {
+			if _, err := newTemplate("handler", tmpl); err != nil {
+				return fmt.Errorf("invalid template of the handler %q: %s", raw.Command, err)
+			}
+		}
+		*h = handler(raw)
+	default:
+		return fmt.Errorf("handler should be a string or a map with command: %v", aux)
+	}
+	return nil
+}
+
+// MarshalYAML marshals the handler into the command string when it has no other options
+func (h handler) MarshalYAML() (interface{}, error) {
+	if reflect.DeepEqual(h, handler{Command: h.Command, Expand: h.Expand}) {
+		return h.Command, nil
+	}
+	type rawHandler handler
+	return rawHandler(h), nil
+}
+
 func loadConfig(file string) (*config, error) {
+	return decodeConfig(file, false)
+}
+
+// loadConfigStrict loads the config like loadConfig but rejects unknown keys
+func loadConfigStrict(file string) (*config, error) {
+	return decodeConfig(file, true)
+}
+
+func decodeConfig(file string, strict bool) (*config, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 	c := &config{}
-	err = yaml.NewDecoder(f).Decode(c)
-	return c, err
+	d := yaml.NewDecoder(f)
+	d.SetStrict(strict)
+	if err := d.Decode(c); err != nil && err != io.EOF {
+		return c, err
+	}
+	return c, nil
+}
+
+// defaultConfigFiles returns the candidates of the config files which are loaded before the
+// file specified by --config in ascending order of precedence. It is a variable to be
+// replaced in tests.
+var defaultConfigFiles = defaultConfigCandidates
+
+// projectConfigEnv is the environment variable to enable the project-local config file
+const projectConfigEnv = "HORENSO_PROJECT_CONFIG"
+
+const projectConfigFile = ".horenso.yaml"
+
+func defaultConfigCandidates() []string {
+	files := []string{"/etc/horenso/config.yaml"}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		files = append(files, filepath.Join(dir, "horenso", "config.yaml"))
+	}
+	// the project-local file is opt-in not to run handlers written in the file of the
+	// directory where the job happens to be started
+	if v, _ := strconv.ParseBool(os.Getenv(projectConfigEnv)); v {
+		files = append(files, projectConfigFile)
+	}
+	return files
+}
+
+// configSources maps the keys of the config to the files which contributed the values
+type configSources map[string][]string
+
+func (cs configSources) String() string {
+	keys := make([]string, 0, len(cs))
+	for k := range cs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf("%s: %s", k, strings.Join(cs[k], ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadConfigFiles loads the config files with their includes and merges them in order.
+// The values in the later file take precedence and the handlers are accumulated. Each file
+// is merged only once even if it is specified or included more than once.
+func loadConfigFiles(files []string, strict bool) (*config, configSources, error) {
+	l := &configLoader{
+		strict:  strict,
+		config:  &config{},
+		sources: configSources{},
+		merged:  map[string]bool{},
+	}
+	for _, file := range files {
+		if err := l.merge(file, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return l.config, l.sources, nil
+}
+
+type configLoader struct {
+	strict  bool
+	config  *config
+	sources configSources
+	merged  map[string]bool
+}
+
+// canonicalPath returns the absolute path of the file with symlinks resolved to detect the
+// same file specified in different ways
+func canonicalPath(file string) string {
+	if p, err := filepath.Abs(file); err == nil {
+		file = p
+	}
+	if p, err := filepath.EvalSymlinks(file); err == nil {
+		file = p
+	}
+	return file
+}
+
+func (l *configLoader) merge(file string, including []string) error {
+	path := canonicalPath(file)
+	for _, f := range including {
+		if f == path {
+			return fmt.Errorf("circular include of the config %q", file)
+		}
+	}
+	if l.merged[path] {
+		return nil
+	}
+	l.merged[path] = true
+	c, err := decodeConfig(file, l.strict)
+	if err == nil {
+		err = c.validateProfiles()
+	}
+	if err == nil {
+		err = expandConfig(c, filepath.Dir(file))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load the config %q: %s", file, err)
+	}
+	// the included files are merged first to be overridden by the including file
+	for _, inc := range c.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(file), inc)
+		}
+		if err := l.merge(inc, append(including, path)); err != nil {
+			return err
+		}
+	}
+	for _, key := range mergeConfig(l.config, c) {
+		l.sources[key] = append(l.sources[key], file)
+	}
+	return nil
+}
+
+// mergeConfig merges the non-zero values of src into dst and returns the keys of the merged
+// values. The handlers are appended, the severities are merged by the exit code and the
+// defaults and the profiles in jobs are merged recursively.
+func mergeConfig(dst, src *config) []string {
+	var keys []string
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		key := strings.Split(sv.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		v := sv.Field(i)
+		switch key {
+		case "include", "defaults", "jobs":
+			continue
+		}
+		if v.IsZero() {
+			continue
+		}
+		d := dv.Field(i)
+		switch {
+		case v.Type() == reflect.TypeOf(handlers{}):
+			d.Set(reflect.AppendSlice(d, v))
+		case v.Kind() == reflect.Map:
+			if d.IsNil() {
+				d.Set(reflect.MakeMap(v.Type()))
+			}
+			for _, k := range v.MapKeys() {
+				d.SetMapIndex(k, v.MapIndex(k))
+			}
+		default:
+			d.Set(v)
+		}
+		keys = append(keys, key)
+	}
+	if src.Defaults != nil {
+		if dst.Defaults == nil {
+			dst.Defaults = &config{}
+		}
+		for _, k := range mergeConfig(dst.Defaults, src.Defaults) {
+			keys = append(keys, "defaults."+k)
+		}
+	}
+	tags := make([]string, 0, len(src.Jobs))
+	for tag := range src.Jobs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if dst.Jobs == nil {
+			dst.Jobs = map[string]*config{}
+		}
+		if dst.Jobs[tag] == nil {
+			dst.Jobs[tag] = &config{}
+		}
+		if j := src.Jobs[tag]; j != nil {
+			for _, k := range mergeConfig(dst.Jobs[tag], j) {
+				keys = append(keys, fmt.Sprintf("jobs.%s.%s", tag, k))
+			}
+		}
+	}
+	return keys
 }
@@ -1,8 +1,14 @@
 package horenso
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -11,10 +17,371 @@ func TestLoadConfig(t *testing.T) {
 		t.Errorf("failed to load config: %s", err)
 	}
 	expect := config{
-		Reporter: []string{"hoge", "fuga"},
-		Noticer:  []string{"bar"},
+		Reporter: handlers{{Command: "hoge"}, {Command: "fuga"}},
+		Noticer:  handlers{{Command: "bar"}},
 	}
 	if !reflect.DeepEqual(expect, *c) {
 		t.Errorf("something went wrong\n   got: %#v\nexpect: %#v", *c, expect)
 	}
 }
+
+func TestLoadConfig_conditions(t *testing.T) {
+	c, err := loadConfig("testdata/config_conditions.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+	if !reflect.DeepEqual(c.Reporter.commands(), []string{"metrics", "pager", "notify"}) {
+		t.Errorf("unexpected reporters: %v", c.Reporter.commands())
+	}
+	if !reflect.DeepEqual(c.Noticer.commands(), []string{"start"}) {
+		t.Errorf("unexpected noticers: %v", c.Noticer.commands())
+	}
+
+	tests := []struct {
+		name   string
+		r      Report
+		expect []bool
+	}{
+		{
+			name:   "success",
+			r:      Report{Status: "success", EndAt: now()},
+			expect: []bool{true, false, false},
+		},
+		{
+			name:   "failure",
+			r:      Report{Status: "failure", ExitCode: 1, EndAt: now()},
+			expect: []bool{true, true, false},
+		},
+		{
+			name:   "exit code 3",
+			r:      Report{Status: "failure", ExitCode: 3, EndAt: now()},
+			expect: []bool{true, true, true},
+		},
+		{
+			name:   "signaled",
+			r:      Report{Status: "failure", ExitCode: 143, Signaled: true, EndAt: now()},
+			expect: []bool{true, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, h := range c.Reporter {
+				if got := h.On.match(tt.r); got != tt.expect[i] {
+					t.Errorf("%s: match should be %t but: %t", h.Command, tt.expect[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig_invalidCondition(t *testing.T) {
+	f := temp()
+	defer os.RemoveAll(f)
+	ioutil.WriteFile(f, []byte("reporter:\n- command: hoge\n  on: exitCode in [a]\n"), 0644)
+	if _, err := loadConfig(f); err == nil {
+		t.Errorf("err shouldn't be nil")
+	}
+}
+
+func TestLoadConfig_maxOutput(t *testing.T) {
+	conf := temp()
+	defer os.RemoveAll(conf)
+	ioutil.WriteFile(conf, []byte("maxOutput: 1024:4096\n"), 0644)
+	ho := &horenso{Config: conf}
+	if err := ho.loadConfig(); err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if ho.MaxOutput != (outputLimit{head: 1024, tail: 4096}) {
+		t.Errorf("unexpected max output: %#v", ho.MaxOutput)
+	}
+
+	ioutil.WriteFile(conf, []byte("maxOutput: -2\n"), 0644)
+	if err := ho.loadConfig(); err == nil || !strings.Contains(err.Error(), "not negative") {
+		t.Errorf("negative max output should be rejected but: %v", err)
+	}
+	if code := Run([]string{"--max-output=-1", "--", "true"}); code != 2 {
+		t.Errorf("negative max output should be a usage error but: %d", code)
+	}
+}
+
+func TestLoadConfig_changeWithoutStateDir(t *testing.T) {
+	conf := temp()
+	defer os.RemoveAll(conf)
+	ioutil.WriteFile(conf, []byte("reporter:\n- command: go version\n  on: change\n"), 0644)
+	ho := &horenso{Config: conf}
+	if err := ho.loadConfig(); err == nil || !strings.Contains(err.Error(), "requires the state directory") {
+		t.Errorf("the change condition without the state directory should be an error but: %v", err)
+	}
+	if errs := ho.validateConfig(conf); len(errs) != 1 || !strings.Contains(errs[0].Error(), "requires the state directory") {
+		t.Errorf("the change condition without the state directory should be invalid but: %v", errs)
+	}
+
+	ho = &horenso{Config: conf, StateDir: "/tmp/horenso-state"}
+	if err := ho.loadConfig(); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		f := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	write("shared.yaml", "reporter: shared\ntimeout: 1m\nseverity:\n  1: warning\n")
+	system := write("system.yaml", "include: [shared.yaml]\nreporter: system\ntimeout: 10m\ntag: system\n")
+	user := write("user.yaml", "tag: user\nseverity:\n  2: unknown\n")
+	empty := write("empty.yaml", "")
+
+	c, sources, err := loadConfigFiles([]string{system, empty, user}, true)
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if !reflect.DeepEqual(c.Reporter.commands(), []string{"shared", "system"}) {
+		t.Errorf("reporters should be accumulated but: %v", c.Reporter.commands())
+	}
+	if c.Timeout != 10*time.Minute || c.Tag != "user" {
+		t.Errorf("the later values should take precedence but: %s, %s", c.Timeout, c.Tag)
+	}
+	if !reflect.DeepEqual(c.Severity, map[int]string{1: "warning", 2: "unknown"}) {
+		t.Errorf("severities should be merged but: %v", c.Severity)
+	}
+	expect := configSources{
+		"reporter": {filepath.Join(dir, "shared.yaml"), system},
+		"timeout":  {filepath.Join(dir, "shared.yaml"), system},
+		"severity": {filepath.Join(dir, "shared.yaml"), user},
+		"tag":      {system, user},
+	}
+	if !reflect.DeepEqual(sources, expect) {
+		t.Errorf("something went wrong\n   got: %v\nexpect: %v", sources, expect)
+	}
+
+	// a file included twice is merged once
+	a := write("a.yaml", "include: [shared.yaml]\n")
+	b := write("b.yaml", "include: [./shared.yaml]\n")
+	c, _, err = loadConfigFiles([]string{a, b}, false)
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if !reflect.DeepEqual(c.Reporter.commands(), []string{"shared"}) {
+		t.Errorf("the shared config should be merged once but: %v", c.Reporter.commands())
+	}
+
+	loop := write("loop.yaml", "include: [loop.yaml]\n")
+	if _, _, err := loadConfigFiles([]string{loop}, false); err == nil || !strings.Contains(err.Error(), "circular include") {
+		t.Errorf("circular include should be an error but: %v", err)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	orig := defaultConfigFiles
+	defer func() { defaultConfigFiles = orig }()
+
+	dir, err := ioutil.TempDir("", "horenso-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "horenso"), 0755)
+	xdg := filepath.Join(dir, "horenso", "config.yaml")
+	ioutil.WriteFile(xdg, []byte("tag: xdg\n"), 0644)
+
+	origXDG, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", origXDG)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+	files := defaultConfigCandidates()
+	if len(files) != 2 || files[1] != xdg {
+		t.Errorf("unexpected default config files: %v", files)
+	}
+	os.Setenv(projectConfigEnv, "1")
+	defer os.Unsetenv(projectConfigEnv)
+	files = defaultConfigCandidates()
+	if len(files) != 3 || files[2] != projectConfigFile {
+		t.Errorf("the project config file should be enabled but: %v", files)
+	}
+
+	defaultConfigFiles = func() []string {
+		return []string{filepath.Join(dir, "not-found.yaml"), xdg}
+	}
+	ho := &horenso{Config: "testdata/config.yaml"}
+	if !reflect.DeepEqual(ho.configFiles(), []string{xdg, "testdata/config.yaml"}) {
+		t.Errorf("unexpected config files: %v", ho.configFiles())
+	}
+	if err := ho.loadConfig(); err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if ho.Tag != "xdg" || !reflect.DeepEqual(ho.reporters.commands(), []string{"hoge", "fuga"}) {
+		t.Errorf("the config files should be merged but: %s, %v", ho.Tag, ho.reporters.commands())
+	}
+
+	// the default config file specified by --config is loaded once
+	ioutil.WriteFile(xdg, []byte("reporter: xdg\n"), 0644)
+	ho = &horenso{Config: filepath.Join(dir, ".", "horenso", "config.yaml")}
+	if files := ho.configFiles(); len(files) != 1 || files[0] != ho.Config {
+		t.Errorf("the duplicated config file should be removed but: %v", files)
+	}
+	ho.loadConfig()
+	if !reflect.DeepEqual(ho.reporters.commands(), []string{"xdg"}) {
+		t.Errorf("the reporter should be loaded once but: %v", ho.reporters.commands())
+	}
+
+	if runtime.GOOS != "windows" {
+		os.Chmod(xdg, 0666)
+		ho = &horenso{}
+		if files := ho.configFiles(); len(files) != 0 {
+			t.Errorf("the config file writable by others should be ignored but: %v", files)
+		}
+	}
+}
+
+func TestLoadConfig_jobs(t *testing.T) {
+	tests := []struct {
+		name      string
+		tag       string
+		reporters []string
+		noticers  []string
+		timeout   time.Duration
+		logfile   string
+		lock      string
+		policy    string
+	}{
+		{
+			name:      "backup",
+			tag:       "backup",
+			reporters: []string{"metrics", "pager"},
+			noticers:  []string{"start"},
+			timeout:   3 * time.Hour,
+			logfile:   "/var/log/horenso/%Y%m%d.log",
+			lock:      "/var/run/horenso/backup.lock",
+			policy:    "wait",
+		},
+		{
+			name:      "cleanup",
+			tag:       "cleanup",
+			reporters: []string{"metrics"},
+			noticers:  []string{"start"},
+			timeout:   time.Hour,
+			logfile:   "/var/log/horenso/cleanup.log",
+			policy:    "skip",
+		},
+		{
+			name:      "unknown tag",
+			tag:       "other",
+			reporters: []string{"metrics"},
+			noticers:  []string{"start"},
+			timeout:   time.Hour,
+			logfile:   "/var/log/horenso/%Y%m%d.log",
+			policy:    "skip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ho := &horenso{Config: "testdata/config_jobs.yaml", Tag: tt.tag}
+			if err := ho.loadConfig(); err != nil {
+				t.Fatalf("err should be nil but: %s", err)
+			}
+			if !reflect.DeepEqual(ho.reporters.commands(), tt.reporters) {
+				t.Errorf("reporters should be %v but: %v", tt.reporters, ho.reporters.commands())
+			}
+			if !reflect.DeepEqual(ho.noticers.commands(), tt.noticers) {
+				t.Errorf("noticers should be %v but: %v", tt.noticers, ho.noticers.commands())
+			}
+			if ho.Timeout != tt.timeout || ho.Logfile != tt.logfile || ho.Lock != tt.lock || ho.LockPolicy != tt.policy {
+				t.Errorf("unexpected settings: %s, %s, %s, %s", ho.Timeout, ho.Logfile, ho.Lock, ho.LockPolicy)
+			}
+		})
+	}
+
+	ho := &horenso{Config: "testdata/config_jobs.yaml", Tag: "backup", Timeout: time.Minute}
+	ho.loadConfig()
+	if ho.Timeout != time.Minute {
+		t.Errorf("the option should take precedence over the profile but: %s", ho.Timeout)
+	}
+	if ho.configSources["jobs.backup.timeout"][0] != "testdata/config_jobs.yaml" {
+		t.Errorf("the source of the profile should be recorded but: %v", ho.configSources)
+	}
+
+	f, _ := ioutil.TempFile("", "horenso-config")
+	f.WriteString("jobs:\n  backup:\n    jobs:\n      nested: {}\n")
+	f.Close()
+	defer os.Remove(f.Name())
+	if _, _, err := loadConfigFiles([]string{f.Name()}, false); err == nil {
+		t.Errorf("nested jobs should be an error")
+	}
+}
+
+func TestLoadConfig_booleans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	shared := filepath.Join(dir, "shared.yaml")
+	ioutil.WriteFile(shared, []byte("timestamp: true\noverrideStatus: true\nspillOutput: true\n"), 0644)
+	conf := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(conf, []byte("include: [shared.yaml]\noverrideStatus: false\nverbose: 2\n"), 0644)
+
+	tests := []struct {
+		name                                string
+		args                                []string
+		timestamp, overrideStatus, spillOut bool
+	}{
+		{
+			name:           "config",
+			timestamp:      true,
+			overrideStatus: false,
+			spillOut:       true,
+		},
+		{
+			name:           "turned on by the flags",
+			args:           []string{"--override-status"},
+			timestamp:      true,
+			overrideStatus: true,
+			spillOut:       true,
+		},
+		{
+			name:           "turned off by the flags",
+			args:           []string{"--no-timestamp", "--no-spill-output", "--override-status", "--no-override-status"},
+			timestamp:      false,
+			overrideStatus: false,
+			spillOut:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ho, _, err := parseArgs(append([]string{"--config", conf}, tt.args...))
+			if err != nil {
+				t.Fatalf("err should be nil but: %s", err)
+			}
+			if err := ho.loadConfig(); err != nil {
+				t.Fatalf("err should be nil but: %s", err)
+			}
+			if ho.TimeStamp != tt.timestamp || ho.OverrideStatus != tt.overrideStatus || ho.SpillOutput != tt.spillOut {
+				t.Errorf("unexpected options: timestamp=%t, overrideStatus=%t, spillOutput=%t",
+					ho.TimeStamp, ho.OverrideStatus, ho.SpillOutput)
+			}
+			if ho.logLevel() != info {
+				t.Errorf("log level should be taken from the config but: %d", ho.logLevel())
+			}
+		})
+	}
+
+	ioutil.WriteFile(conf, []byte("strictConfig: true\nreporters: typo\n"), 0644)
+	ho := &horenso{Config: conf}
+	if err := ho.loadConfig(); err == nil || !ho.StrictConfig {
+		t.Errorf("strictConfig in the config should reject unknown keys")
+	}
+}
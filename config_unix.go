@@ -0,0 +1,23 @@
+//go:build !windows
+
+package horenso
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkConfigPermission refuses the config file which can be modified by other users than
+// the current user and root
+func checkConfigPermission(fi os.FileInfo) error {
+	if fi.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("writable by group or others")
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if uid := int(st.Uid); uid != 0 && uid != os.Getuid() {
+			return fmt.Errorf("owned by another user (uid: %d)", uid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package horenso
+
+import "os"
+
+func checkConfigPermission(fi os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,234 @@
+package horenso
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/lestrrat-go/strftime"
+	"gopkg.in/yaml.v2"
+)
+
+// validateConfig loads the config and its includes strictly and returns the problems found in it
+func (ho *horenso) validateConfig(file string) []error {
+	c, _, err := loadConfigFiles([]string{file}, true)
+	if err != nil {
+		return []error{err}
+	}
+	errs := ho.validateValues(c)
+	if c.Defaults != nil {
+		for _, err := range ho.validateValues(c.Defaults) {
+			errs = append(errs, fmt.Errorf("defaults: %s", err))
+		}
+	}
+	tags := make([]string, 0, len(c.Jobs))
+	for tag := range c.Jobs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		for _, err := range ho.validateValues(c.Jobs[tag]) {
+			errs = append(errs, fmt.Errorf("jobs.%s: %s", tag, err))
+		}
+	}
+	// the state directory and the handlers are checked in each profile they are merged into
+	var topErr error
+	for _, tag := range append([]string{""}, tags...) {
+		p := c.profile(tag)
+		stateDir := ho.StateDir
+		if stateDir == "" {
+			stateDir = p.StateDir
+		}
+		err := checkChangeCondition(stateDir, p.Reporter, p.Noticer)
+		switch {
+		case err == nil:
+		case tag == "":
+			topErr = err
+			errs = append(errs, err)
+		case topErr == nil || err.Error() != topErr.Error():
+			errs = append(errs, fmt.Errorf("jobs.%s: %s", tag, err))
+		}
+	}
+	return errs
+}
+
+func (ho *horenso) validateValues(c *config) []error {
+	var errs []error
+	if c.Logfile != "" {
+		if _, err := strftime.New(c.Logfile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid log %q: %s", c.Logfile, err))
+		}
+	}
+	switch c.LockPolicy {
+	case "", lockPolicySkip, lockPolicyWait, lockPolicyFail:
+	default:
+		errs = append(errs, fmt.Errorf("invalid lockPolicy %q", c.LockPolicy))
+	}
+	switch c.PrintReport {
+	case "", "stdout", "stderr":
+	default:
+		errs = append(errs, fmt.Errorf("invalid printReport %q", c.PrintReport))
+	}
+	if _, err := newTemplate("format", c.Format); err != nil {
+		errs = append(errs, fmt.Errorf("invalid format: %s", err))
+	}
+	if err := validateSeverities(c.Severity); err != nil {
+		errs = append(errs, err)
+	}
+	for _, h := range append(c.Noticer, c.Reporter...) {
+		if err := ho.validateHandler(h); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateHandler checks that the command of the handler can be found in PATH
+func (ho *horenso) validateHandler(h handler) error {
+	switch {
+	case isWebhook(h), isSlack(h), isMail(h), isSyslog(h), isJournald(h):
+		return nil
+	}
+	args, err := ho.splitHandlerCmdStr(h.Command)
+	if err != nil || len(args) < 1 {
+		return fmt.Errorf("invalid handler %q: invalid handler arguments", h.Command)
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return fmt.Errorf("invalid handler %q: %s", h.Command, err)
+	}
+	return nil
+}
+
+// effectiveConfig returns the settings merged from the options and the config
+func (ho *horenso) effectiveConfig() *config {
+	return &config{
+		Reporter:         ho.reporters,
+		Noticer:          ho.noticers,
+		Timestamp:        &ho.TimeStamp,
+		Tag:              ho.Tag,
+		OverrideStatus:   &ho.OverrideStatus,
+		Verbose:          len(ho.Verbose),
+		Logfile:          ho.Logfile,
+		StrictConfig:     ho.StrictConfig,
+		Timeout:          ho.Timeout,
+		KillAfter:        ho.KillAfter,
+		Lock:             ho.Lock,
+		LockPolicy:       ho.LockPolicy,
+		LockWait:         ho.LockWait,
+		MaxOutput:        ho.MaxOutput,
+		SpillOutput:      &ho.SpillOutput,
+		KeepOutput:       &ho.KeepOutput,
+		HandlerTimeout:   ho.HandlerTimeout,
+		HandlerRetry:     ho.HandlerRetry,
+		SpoolDir:         ho.SpoolDir,
+		Format:           ho.Format,
+		PrintReport:      ho.PrintReport,
+		StateDir:         ho.StateDir,
+		HistoryDir:       ho.HistoryDir,
+		HistoryRetention: ho.HistoryRetention,
+		SuccessExitCodes: ho.successExitCodes,
+		Severity:         ho.severities,
+	}
+}
+
+func (ho *horenso) configFile() string {
+	if ho.Config != "" {
+		return ho.Config
+	}
+	return os.Getenv("HORENSO_CONFIG")
+}
+
+// configFiles returns the existing default config files and the specified config file in
+// ascending order of precedence. The default config files which can be modified by other
+// users are ignored, and a file listed more than once is kept only at the last position.
+func (ho *horenso) configFiles() []string {
+	var candidates []string
+	for _, f := range defaultConfigFiles() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if err := checkConfigPermission(fi); err != nil {
+			ho.logf(warn, "ignored the config file %q: %s", f, err)
+			continue
+		}
+		candidates = append(candidates, f)
+	}
+	if conf := ho.configFile(); conf != "" {
+		candidates = append(candidates, conf)
+	}
+	var files []string
+	for i, f := range candidates {
+		dup := false
+		for _, later := range candidates[i+1:] {
+			if canonicalPath(f) == canonicalPath(later) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func runConfig(args []string) int {
+	ho := &horenso{outStream: os.Stdout, errStream: os.Stderr}
+	p := flags.NewParser(ho, flags.Default)
+	p.Usage = "config validate [--config=/path/to/config.yaml]\n  config show [OPTIONS]"
+	if len(args) < 1 || (args[0] != "validate" && args[0] != "show") {
+		p.WriteHelp(ho.errStream)
+		return 2
+	}
+	rest, err := p.ParseArgs(args[1:])
+	if err != nil || len(rest) > 0 {
+		if ferr, ok := err.(*flags.Error); !ok || ferr.Type != flags.ErrHelp {
+			p.WriteHelp(ho.errStream)
+		}
+		return 2
+	}
+	ho.setupLog()
+
+	if args[0] == "validate" {
+		files := ho.configFiles()
+		if len(files) == 0 {
+			fmt.Fprintln(ho.errStream, "no config files are found")
+			return 2
+		}
+		ret := 0
+		for _, file := range files {
+			errs := ho.validateConfig(file)
+			for _, err := range errs {
+				fmt.Fprintf(ho.errStream, "%s: %s\n", file, err)
+			}
+			if len(errs) > 0 {
+				ret = 1
+				continue
+			}
+			fmt.Fprintf(ho.outStream, "%s: ok\n", file)
+		}
+		return ret
+	}
+
+	if err := ho.loadConfig(); err != nil {
+		fmt.Fprintf(ho.errStream, "failed to load config: %s\n", err)
+		return 1
+	}
+	b, err := yaml.Marshal(ho.effectiveConfig())
+	if err != nil {
+		fmt.Fprintf(ho.errStream, "failed to marshal the config: %s\n", err)
+		return 1
+	}
+	if len(ho.configSources) > 0 {
+		fmt.Fprintln(ho.outStream, "# values from the config files:")
+		for _, l := range strings.Split(ho.configSources.String(), "\n") {
+			fmt.Fprintf(ho.outStream, "#   %s\n", l)
+		}
+	}
+	ho.outStream.Write(b)
+	return 0
+}
@@ -0,0 +1,81 @@
+package horenso
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidateConfig(t *testing.T) {
+	ho := &horenso{}
+	if errs := ho.validateConfig("testdata/config_status.yaml"); len(errs) != 0 {
+		t.Errorf("errs should be empty but: %v", errs)
+	}
+
+	errs := ho.validateConfig("testdata/config_invalid.yaml")
+	expects := []string{
+		`invalid log "/tmp/horenso.%Q.log"`,
+		`invalid lockPolicy "never"`,
+		`invalid handler "horenso-no-such-command"`,
+	}
+	if len(errs) != len(expects) {
+		t.Fatalf("%d errors should be found but: %v", len(expects), errs)
+	}
+	for i, e := range expects {
+		if !strings.HasPrefix(errs[i].Error(), e) {
+			t.Errorf("error should start with %q but: %s", e, errs[i])
+		}
+	}
+
+	f, _ := ioutil.TempFile("", "horenso-config")
+	f.WriteString("reporter: go run testdata/reporter.go\nreporters: typo\n")
+	f.Close()
+	defer os.Remove(f.Name())
+	if errs := ho.validateConfig(f.Name()); len(errs) != 1 || !strings.Contains(errs[0].Error(), "field reporters not found") {
+		t.Errorf("unknown keys should be rejected but: %v", errs)
+	}
+	if _, err := loadConfig(f.Name()); err != nil {
+		t.Errorf("unknown keys should be ignored without strict but: %s", err)
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	_, ho, _, err := parseArgs([]string{
+		"--config", "testdata/config_conditions.yaml",
+		"--reporter", "extra",
+		"--timeout", "10m",
+		"--tag", "backup",
+	})
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if err := ho.loadConfig(); err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	b, err := yaml.Marshal(ho.effectiveConfig())
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if !strings.Contains(string(b), "timeout: 10m0s\n") {
+		t.Errorf("timeout should be shown but: %s", string(b))
+	}
+
+	c := &config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		t.Fatalf("the shown config should be loadable but: %s", err)
+	}
+	expect := []string{"extra", "metrics", "pager", "notify"}
+	if !reflect.DeepEqual(c.Reporter.commands(), expect) {
+		t.Errorf("reporters should be %v but: %v", expect, c.Reporter.commands())
+	}
+	if c.Reporter[2].On.String() != "failure" || c.Noticer[0].On.String() != "always" {
+		t.Errorf("conditions should be kept but: %s", string(b))
+	}
+	if c.Tag != "backup" {
+		t.Errorf("tag should be backup but: %s", c.Tag)
+	}
+}
@@ -0,0 +1,54 @@
+package horenso
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// phases of the handlers which are passed as HORENSO_PHASE
+const (
+	phaseNotice = "notice"
+	phaseReport = "report"
+)
+
+// handlerEnv returns the environment variables describing the job for the handler process.
+// The report is also written to a temporary file passed as HORENSO_REPORT_FILE, and the
+// returned function removes it.
+func handlerEnv(report []byte, phase string) ([]string, func(), error) {
+	var r Report
+	if err := json.Unmarshal(report, &r); err != nil {
+		return nil, nil, err
+	}
+	f, err := ioutil.TempFile("", "horenso-report-*.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = f.Write(report)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	var startAt string
+	if r.StartAt != nil {
+		startAt = r.StartAt.Format(time.RFC3339)
+	}
+	env := append(os.Environ(),
+		"HORENSO_TAG="+r.Tag,
+		"HORENSO_EXIT_CODE="+strconv.Itoa(r.ExitCode),
+		"HORENSO_RESULT="+r.Result,
+		"HORENSO_COMMAND="+r.Command,
+		"HORENSO_PHASE="+phase,
+		"HORENSO_START_AT="+startAt,
+		"HORENSO_DURATION="+strconv.FormatFloat(reportDuration(r).Seconds(), 'f', -1, 64),
+		"HORENSO_REPORT_FILE="+f.Name(),
+	)
+	return env, cleanup, nil
+}
@@ -0,0 +1,186 @@
+package horenso
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var (
+	referenceReg = regexp.MustCompile(`\$?\$\{[^}]*\}`)
+	envNameReg   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// expandString expands the references in the string. "${VAR}" is replaced with the
+// environment variable which is required, "${VAR:-default}" falls back to the default when
+// the variable is unset or empty and "${file:/path}" is replaced with the content of the file
+// without trailing newlines. The relative path is resolved from dir. "$${...}" is left as
+// "${...}".
+func expandString(s, dir string) (string, error) {
+	return replaceReferences(s, func(ref string) (string, error) {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:], nil
+		}
+		return resolveReference(ref[2:len(ref)-1], dir)
+	})
+}
+
+// checkReferences checks that the references in the string can be resolved and returns the
+// string whose relative paths in "${file:...}" are resolved from dir. The references are
+// kept to be expanded later.
+func checkReferences(s, dir string) (string, error) {
+	return replaceReferences(s, func(ref string) (string, error) {
+		if strings.HasPrefix(ref, "$$") {
+			return ref, nil
+		}
+		expr := ref[2 : len(ref)-1]
+		if _, err := resolveReference(expr, dir); err != nil {
+			return "", err
+		}
+		if file := strings.TrimPrefix(expr, "file:"); file != expr && !filepath.IsAbs(file) {
+			return "${file:" + filepath.Join(dir, file) + "}", nil
+		}
+		return ref, nil
+	})
+}
+
+func replaceReferences(s string, fn func(ref string) (string, error)) (string, error) {
+	var err error
+	ret := referenceReg.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		var v string
+		v, err = fn(ref)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", ref, err)
+		}
+		return v
+	})
+	return ret, err
+}
+
+func resolveReference(ref, dir string) (string, error) {
+	if strings.HasPrefix(ref, "file:") {
+		file := strings.TrimPrefix(ref, "file:")
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the file: %s", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	if !envNameReg.MatchString(name) {
+		return "", fmt.Errorf("invalid reference")
+	}
+	v, ok := os.LookupEnv(name)
+	if hasDefault && v == "" {
+		return def, nil
+	}
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is required but not set", name)
+	}
+	return v, nil
+}
+
+// expandConfig expands the references in all string values of the config. The references
+// in the handlers are only checked here and expanded on running the handlers, not to leak
+// secrets to logs and spool entries.
+func expandConfig(c *config, dir string) error {
+	markExpand(c)
+	return mapStrings(reflect.ValueOf(c).Elem(),
+		func(s string) (string, error) { return expandString(s, dir) },
+		func(s string) (string, error) { return checkReferences(s, dir) })
+}
+
+// markExpand marks the handlers in the config to be expanded on running them
+func markExpand(c *config) {
+	if c == nil {
+		return
+	}
+	for _, hs := range []handlers{c.Reporter, c.Noticer} {
+		for i := range hs {
+			hs[i].Expand = true
+		}
+	}
+	markExpand(c.Defaults)
+	for _, jc := range c.Jobs {
+		markExpand(jc)
+	}
+}
+
+// expand returns the copy of the handler whose references are expanded. The handlers
+// specified by the command line options are returned as they are.
+func (h handler) expand() (handler, error) {
+	if !h.Expand {
+		return h, nil
+	}
+	e := h
+	e.rawCommand = h.Command
+	// copy the maps and the slices not to modify the original handler
+	if h.Headers != nil {
+		e.Headers = make(map[string]string, len(h.Headers))
+		for k, v := range h.Headers {
+			e.Headers[k] = v
+		}
+	}
+	e.To = append([]string(nil), h.To...)
+	expand := func(s string) (string, error) { return expandString(s, "") }
+	err := mapStrings(reflect.ValueOf(&e).Elem(), expand, expand)
+	return e, err
+}
+
+var handlerType = reflect.TypeOf(handler{})
+
+// mapStrings replaces all settable string values in v with fn. handlerFn is used instead in
+// handlers.
+func mapStrings(v reflect.Value, fn, handlerFn func(string) (string, error)) error {
+	if v.Type() == handlerType {
+		fn = handlerFn
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		s, err := fn(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return mapStrings(v.Elem(), fn, handlerFn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := mapStrings(v.Field(i), fn, handlerFn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := mapStrings(v.Index(i), fn, handlerFn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			// map values are not addressable
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			if err := mapStrings(e, fn, handlerFn); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+	}
+	return nil
+}
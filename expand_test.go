@@ -0,0 +1,167 @@
+package horenso
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandString(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-expand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0600)
+	os.Setenv("HORENSO_TEST_HOST", "example.com")
+	os.Setenv("HORENSO_TEST_EMPTY", "")
+	defer os.Unsetenv("HORENSO_TEST_HOST")
+	defer os.Unsetenv("HORENSO_TEST_EMPTY")
+
+	tests := []struct {
+		in, expect string
+	}{
+		{"webhook+https://${HORENSO_TEST_HOST}/hook", "webhook+https://example.com/hook"},
+		{"${HORENSO_TEST_UNSET:-#general}", "#general"},
+		{"${HORENSO_TEST_EMPTY:-default}", "default"},
+		{"${HORENSO_TEST_EMPTY}", ""},
+		{"Bearer ${file:token}", "Bearer s3cr3t"},
+		{"Bearer ${file:" + filepath.Join(dir, "token") + "}", "Bearer s3cr3t"},
+		{"echo $HOME $${HORENSO_TEST_HOST}", "echo $HOME ${HORENSO_TEST_HOST}"},
+	}
+	for _, tt := range tests {
+		got, err := expandString(tt.in, dir)
+		if err != nil {
+			t.Errorf("%q: err should be nil but: %s", tt.in, err)
+		}
+		if got != tt.expect {
+			t.Errorf("%q: expect %q but got %q", tt.in, tt.expect, got)
+		}
+	}
+
+	errTests := []struct {
+		in, expect string
+	}{
+		{"${HORENSO_TEST_UNSET}", `${HORENSO_TEST_UNSET}: environment variable "HORENSO_TEST_UNSET" is required but not set`},
+		{"${file:not-found}", "${file:not-found}: failed to read the file:"},
+		{"${HORENSO TEST}", "${HORENSO TEST}: invalid reference"},
+	}
+	for _, tt := range errTests {
+		if _, err := expandString(tt.in, dir); err == nil || !strings.HasPrefix(err.Error(), tt.expect) {
+			t.Errorf("%q: error should start with %q but: %v", tt.in, tt.expect, err)
+		}
+	}
+}
+
+func TestLoadConfig_expand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-expand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0600)
+	conf := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(conf, []byte(`reporter:
+- command: webhook+https://${HORENSO_TEST_HOST}/hook
+  headers:
+    Authorization: Bearer ${file:token}
+  on: failure
+noticer:
+- sh -c 'for f in a b; do echo $${f}; done'
+log: ${HORENSO_TEST_LOGDIR:-/var/log/horenso}/%Y%m%d.log
+jobs:
+  backup:
+    tag: ${HORENSO_TEST_HOST}
+`), 0644)
+	os.Setenv("HORENSO_TEST_HOST", "example.com")
+	defer os.Unsetenv("HORENSO_TEST_HOST")
+
+	c, _, err := loadConfigFiles([]string{conf}, true)
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	h := c.Reporter[0]
+	token := "Bearer ${file:" + filepath.Join(dir, "token") + "}"
+	if h.Command != "webhook+https://${HORENSO_TEST_HOST}/hook" || h.Headers["Authorization"] != token {
+		t.Errorf("the handler should not be expanded until running it but: %#v", h)
+	}
+	e, err := h.expand()
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if e.Command != "webhook+https://example.com/hook" || e.Headers["Authorization"] != "Bearer s3cr3t" {
+		t.Errorf("the handler should be expanded but: %#v", e)
+	}
+	if e.name() != h.Command || h.Headers["Authorization"] != token {
+		t.Errorf("the original handler should be kept but: %q, %#v", e.name(), h)
+	}
+	n, err := c.Noticer[0].expand()
+	if err != nil || n.Command != "sh -c 'for f in a b; do echo ${f}; done'" {
+		t.Errorf("the escaped reference should be passed to the shell but: %q, %v", n.Command, err)
+	}
+	if h.On.String() != "failure" {
+		t.Errorf("the conditions should be kept but: %s", h.On)
+	}
+	if c.Logfile != "/var/log/horenso/%Y%m%d.log" || c.Jobs["backup"].Tag != "example.com" {
+		t.Errorf("the values should be expanded but: %s, %s", c.Logfile, c.Jobs["backup"].Tag)
+	}
+
+	os.Unsetenv("HORENSO_TEST_HOST")
+	_, _, err = loadConfigFiles([]string{conf}, true)
+	if err == nil || !strings.Contains(err.Error(), `environment variable "HORENSO_TEST_HOST" is required but not set`) {
+		t.Errorf("missing variable should be an error but: %v", err)
+	}
+}
+
+func TestHandlerExpand_commandLine(t *testing.T) {
+	ho := &horenso{Reporter: []string{"sh -c 'for f in a b; do echo ${f}; done'"}}
+	if err := ho.loadConfig(); err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	h, err := ho.reporters[0].expand()
+	if err != nil || h.Command != ho.Reporter[0] {
+		t.Errorf("the handler of the command line should not be expanded but: %q, %v", h.Command, err)
+	}
+}
+
+func TestDeliver_hideSecrets(t *testing.T) {
+	spoolDir, err := ioutil.TempDir("", "horenso-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+	os.Setenv("HORENSO_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("HORENSO_TEST_SECRET")
+	var b bytes.Buffer
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stderr)
+
+	ho := &horenso{SpoolDir: spoolDir, Verbose: []bool{true, true}}
+	report := []byte(`{"command":"echo","exitCode":1}`)
+	for _, h := range []handler{
+		{Command: "testdata/notfound ${HORENSO_TEST_SECRET}"},
+		{Command: "webhook+http://127.0.0.1:0/${HORENSO_TEST_SECRET}"},
+	} {
+		if err := ho.deliver(h, report, phaseReport); err == nil || strings.Contains(err.Error(), "s3cr3t") {
+			t.Errorf("%q: error should not contain the secret but: %v", h.Command, err)
+		}
+	}
+	out := b.String()
+	if strings.Contains(out, "s3cr3t") || !strings.Contains(out, "${HORENSO_TEST_SECRET}") {
+		t.Errorf("logs should not contain the secret but: %s", out)
+	}
+	files, _ := filepath.Glob(filepath.Join(spoolDir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("the reports should be spooled but: %v", files)
+	}
+	for _, f := range files {
+		b, _ := ioutil.ReadFile(f)
+		if strings.Contains(string(b), "s3cr3t") || !strings.Contains(string(b), "${HORENSO_TEST_SECRET}") {
+			t.Errorf("spool entries should not contain the secret but: %s", b)
+		}
+	}
+}
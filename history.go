@@ -0,0 +1,334 @@
+package horenso
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultHistoryRetention = 100
+	historyMaxOutput        = 64 * 1024
+	historyLockTimeout      = 10 * time.Second
+	// the history is trimmed when it exceeds the retention by 1/historyTrimSlack
+	historyTrimSlack = 10
+)
+
+func (ho *horenso) historyFile(r Report) string {
+	return filepath.Join(ho.HistoryDir, jobKey(r)+".jsonl")
+}
+
+func (ho *horenso) historyRetention() int {
+	if ho.HistoryRetention > 0 {
+		return ho.HistoryRetention
+	}
+	return defaultHistoryRetention
+}
+
+// truncateHead keeps the last n bytes of s and reports whether it is truncated
+func truncateHead(n int, s string) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	return s[len(s)-n:], true
+}
+
+// readTail reads the last n bytes of the file and reports whether it is truncated
+func readTail(file string, n int64) (string, bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	truncated := fi.Size() > n
+	if truncated {
+		if _, err := f.Seek(-n, io.SeekEnd); err != nil {
+			return "", false, err
+		}
+	}
+	b, err := ioutil.ReadAll(f)
+	return string(b), truncated, err
+}
+
+// historyOutputs sets the outputs of the report to be recorded in the history. They are
+// truncated to the last historyMaxOutput bytes, and read from the spilled output files when
+// they are not captured in the report.
+func historyOutputs(r *Report) {
+	spilled := r.OutputFile != "" && r.Output == "" && r.Stdout == "" && r.Stderr == ""
+	for _, v := range []struct {
+		s    *string
+		file string
+	}{
+		{&r.Output, r.OutputFile},
+		{&r.Stdout, r.StdoutFile},
+		{&r.Stderr, r.StderrFile},
+	} {
+		var truncated bool
+		if spilled && v.file != "" {
+			s, t, err := readTail(v.file, historyMaxOutput)
+			if err != nil {
+				continue
+			}
+			*v.s, truncated = s, t
+		} else {
+			*v.s, truncated = truncateHead(historyMaxOutput, *v.s)
+		}
+		if truncated {
+			r.OutputTruncated = true
+		}
+	}
+}
+
+// lockHistory takes the lock of the history file to serialize the writers
+func lockHistory(file string) (*os.File, error) {
+	f, err := os.OpenFile(file+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(historyLockTimeout)
+	for {
+		err = tryLock(f)
+		if err != errLockHeld || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(lockPollInterval)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// recordHistory appends the report to the history of the job. The history is trimmed to the
+// retention only when it has grown by historyTrimSlack of the retention, not to rewrite the
+// whole file on every run.
+func (ho *horenso) recordHistory(r Report) error {
+	if err := os.MkdirAll(ho.HistoryDir, 0755); err != nil {
+		return err
+	}
+	historyOutputs(&r)
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	file := ho.historyFile(r)
+	lock, err := lockHistory(file)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	retention := ho.historyRetention()
+	if n, err := countLines(file); err != nil || n <= retention+retention/historyTrimSlack {
+		return err
+	}
+	return ho.trimHistory(file, retention)
+}
+
+// trimHistory drops the old entries of the history exceeding the retention
+func (ho *horenso) trimHistory(file string, retention int) error {
+	lines, err := readLines(file)
+	if err != nil {
+		return err
+	}
+	if len(lines) > retention {
+		lines = lines[len(lines)-retention:]
+	}
+	f, err := ioutil.TempFile(ho.HistoryDir, ".history-")
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		f.Write(l)
+		f.Write([]byte("\n"))
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+func countLines(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	buf := make([]byte, 32*1024)
+	for {
+		c, err := f.Read(buf)
+		n += bytes.Count(buf[:c], []byte("\n"))
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func readLines(file string) ([][]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines [][]byte
+	br := bufio.NewReader(f)
+	for {
+		l, err := br.ReadBytes('\n')
+		if l = bytes.TrimSpace(l); len(l) > 0 {
+			lines = append(lines, l)
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// loadHistory returns the reports of the job with the tag in chronological order
+func (ho *horenso) loadHistory(tag string) ([]Report, error) {
+	lines, err := readLines(ho.historyFile(Report{Tag: tag}))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no history found for the tag %q", tag)
+		}
+		return nil, err
+	}
+	// the history may have a few more entries than the retention until it is trimmed
+	if retention := ho.historyRetention(); len(lines) > retention {
+		lines = lines[len(lines)-retention:]
+	}
+	reports := make([]Report, 0, len(lines))
+	for _, l := range lines {
+		var r Report
+		if err := json.Unmarshal(l, &r); err != nil {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+func printHistory(w io.Writer, reports []Report) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "START\tDURATION\tEXIT CODE\tSTATUS\tRESULT")
+	for _, r := range reports {
+		start := "-"
+		if r.StartAt != nil {
+			start = r.StartAt.Format(time.RFC3339)
+		}
+		duration := "-"
+		if r.EndAt != nil {
+			duration = reportDuration(r).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", start, duration, r.ExitCode, r.Status, r.Result)
+	}
+	tw.Flush()
+}
+
+type historyOpts struct {
+	HistoryDir string `long:"history-dir" value-name:"/path/to/history" description:"history directory"`
+	Config     string `short:"c" long:"config" value-name:"/path/to/config.yaml" description:"config file"`
+	Output     bool   `long:"output" description:"print the captured output of the last run (last only)"`
+}
+
+// parseHistoryArgs parses the arguments of the history and last subcommands
+func parseHistoryArgs(usage string, args []string) (*horenso, *historyOpts, string, int) {
+	opts := &historyOpts{}
+	p := flags.NewParser(opts, flags.Default)
+	p.Usage = usage
+	rest, err := p.ParseArgs(args)
+	if err != nil || len(rest) != 1 {
+		if ferr, ok := err.(*flags.Error); !ok || ferr.Type != flags.ErrHelp {
+			p.WriteHelp(os.Stderr)
+		}
+		return nil, nil, "", 2
+	}
+	ho := &horenso{
+		HistoryDir: opts.HistoryDir,
+		Config:     opts.Config,
+		outStream:  os.Stdout,
+		errStream:  os.Stderr,
+	}
+	ho.setupLog()
+	if err := ho.loadConfig(); err != nil {
+		fmt.Fprintf(ho.errStream, "failed to load config: %s\n", err)
+	}
+	if ho.HistoryDir == "" {
+		fmt.Fprintln(ho.errStream, "history directory is not specified")
+		return nil, nil, "", 2
+	}
+	return ho, opts, rest[0], 0
+}
+
+func runHistory(args []string) int {
+	ho, _, tag, code := parseHistoryArgs("history [--history-dir=/path/to/history] <tag>", args)
+	if ho == nil {
+		return code
+	}
+	reports, err := ho.loadHistory(tag)
+	if err != nil {
+		fmt.Fprintln(ho.errStream, err)
+		return 1
+	}
+	printHistory(ho.outStream, reports)
+	return 0
+}
+
+func runLast(args []string) int {
+	ho, opts, tag, code := parseHistoryArgs("last [--history-dir=/path/to/history] [--output] <tag>", args)
+	if ho == nil {
+		return code
+	}
+	reports, err := ho.loadHistory(tag)
+	if err != nil || len(reports) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no history found for the tag %q", tag)
+		}
+		fmt.Fprintln(ho.errStream, err)
+		return 1
+	}
+	r := reports[len(reports)-1]
+	if opts.Output {
+		io.WriteString(ho.outStream, r.Output)
+		return 0
+	}
+	out, err := renderTemplate("report", defaultReportFormat, r)
+	if err != nil {
+		fmt.Fprintln(ho.errStream, err)
+		return 1
+	}
+	io.WriteString(ho.outStream, out)
+	return 0
+}
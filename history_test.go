@@ -0,0 +1,127 @@
+package horenso
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ho := &horenso{HistoryDir: dir, HistoryRetention: 2}
+
+	start := time.Date(2022, 8, 12, 4, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		st := start.Add(time.Duration(i) * time.Hour)
+		end := st.Add(time.Duration(i+1) * time.Second)
+		r := Report{
+			Command:  "backup.sh",
+			Tag:      "backup",
+			Output:   strings.Repeat("x", historyMaxOutput) + "end\n",
+			ExitCode: i,
+			Result:   fmt.Sprintf("command exited with code: %d", i),
+			StartAt:  &st,
+			EndAt:    &end,
+		}
+		ho.resolveStatus(&r)
+		if err := ho.recordHistory(r); err != nil {
+			t.Fatalf("failed to record history: %s", err)
+		}
+	}
+
+	reports, err := ho.loadHistory("backup")
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("history should have 2 entries but: %d", len(reports))
+	}
+	if reports[0].ExitCode != 1 || reports[1].ExitCode != 2 {
+		t.Errorf("old entries should be dropped but: %d, %d", reports[0].ExitCode, reports[1].ExitCode)
+	}
+	if len(reports[1].Output) != historyMaxOutput || !strings.HasSuffix(reports[1].Output, "end\n") {
+		t.Errorf("output should be truncated to the last %d bytes", historyMaxOutput)
+	}
+	if !reports[1].OutputTruncated {
+		t.Errorf("OutputTruncated should be true")
+	}
+
+	var b bytes.Buffer
+	printHistory(&b, reports)
+	expect := `START                 DURATION  EXIT CODE  STATUS   RESULT
+2022-08-12T05:00:00Z  2s        1          failure  command exited with code: 1
+2022-08-12T06:00:00Z  3s        2          failure  command exited with code: 2
+`
+	if b.String() != expect {
+		t.Errorf("history should be\n%s\nbut:\n%s", expect, b.String())
+	}
+
+	if _, err := ho.loadHistory("unknown"); err == nil {
+		t.Errorf("err shouldn't be nil")
+	}
+}
+
+func TestRecordHistory_append(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ho := &horenso{HistoryDir: dir, HistoryRetention: 10}
+	file := ho.historyFile(Report{Tag: "backup"})
+
+	for i := 0; i < 25; i++ {
+		if err := ho.recordHistory(Report{Tag: "backup", ExitCode: i}); err != nil {
+			t.Fatalf("failed to record history: %s", err)
+		}
+		n, _ := countLines(file)
+		if n > 11 {
+			t.Fatalf("history should be trimmed but has %d entries", n)
+		}
+	}
+	reports, err := ho.loadHistory("backup")
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if len(reports) != 10 || reports[0].ExitCode != 15 || reports[9].ExitCode != 24 {
+		t.Errorf("the last 10 entries should be loaded but: %d entries", len(reports))
+	}
+}
+
+func TestRun_historySpillOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--spill-output",
+		"--history-dir", dir,
+		"--tag", "spill",
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+	if _, err := ho.run(cmdArgs); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	reports, err := ho.loadHistory("spill")
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("history should have an entry but: %v", err)
+	}
+	if r := reports[0]; r.Output != "1\n2\n3\n" || r.Stdout != "1\n2\n3\n" || r.OutputTruncated {
+		t.Errorf("the spilled output should be recorded but: %#v", r)
+	}
+}
@@ -3,6 +3,7 @@ package horenso
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -22,14 +23,42 @@ import (
 )
 
 type horenso struct {
-	Reporter       []string `short:"r" long:"reporter" value-name:"/path/to/reporter.pl" description:"handler for reporting the result of the job"`
-	Noticer        []string `short:"n" long:"noticer" value-name:"'ruby /path/to/noticer.rb'" description:"handler for noticing the start of the job"`
-	TimeStamp      bool     `short:"T" long:"timestamp" description:"add timestamp to merged output"`
-	Tag            string   `short:"t" long:"tag" value-name:"job-name" description:"tag of the job"`
-	OverrideStatus bool     `short:"o" long:"override-status" description:"override command exit status, always exit 0"`
-	Verbose        []bool   `short:"v" long:"verbose" description:"verbose output. it can be stacked like -vv for more detailed log"`
-	Logfile        string   `short:"l" long:"log" value-name:"/path/to/logfile" description:"logfile path. The strftime format like '%Y%m%d.log' is available."`
-	Config         string   `short:"c" long:"config" value-name:"/path/to/config.yaml" description:"config file"`
+	Reporter         []string      `short:"r" long:"reporter" value-name:"/path/to/reporter.pl" description:"handler for reporting the result of the job"`
+	Noticer          []string      `short:"n" long:"noticer" value-name:"'ruby /path/to/noticer.rb'" description:"handler for noticing the start of the job"`
+	TimeStamp        bool          `short:"T" long:"timestamp" description:"add timestamp to merged output"`
+	NoTimeStamp      bool          `long:"no-timestamp" description:"don't add timestamp to merged output even if the config enables it"`
+	Tag              string        `short:"t" long:"tag" value-name:"job-name" description:"tag of the job"`
+	OverrideStatus   bool          `short:"o" long:"override-status" description:"override command exit status, always exit 0"`
+	NoOverrideStatus bool          `long:"no-override-status" description:"don't override command exit status even if the config enables it"`
+	Verbose          []bool        `short:"v" long:"verbose" description:"verbose output. it can be stacked like -vv for more detailed log"`
+	Logfile          string        `short:"l" long:"log" value-name:"/path/to/logfile" description:"logfile path. The strftime format like '%Y%m%d.log' is available."`
+	Config           string        `short:"c" long:"config" value-name:"/path/to/config.yaml" description:"config file"`
+	StrictConfig     bool          `long:"strict-config" description:"reject unknown keys in the config and don't run the command when the config is broken"`
+	Timeout          time.Duration `long:"timeout" value-name:"duration" description:"send SIGTERM to the command after the duration like '30m'"`
+	KillAfter        time.Duration `long:"kill-after" value-name:"duration" description:"grace period before sending SIGKILL after the timeout (default: 10s)"`
+	Lock             string        `long:"lock" value-name:"/path/to/lockfile" description:"take an exclusive lock of the file before running the command"`
+	LockPolicy       string        `long:"lock-policy" choice:"skip" choice:"wait" choice:"fail" description:"what to do when the lock is held by another process (default: skip)"`
+	LockWait         time.Duration `long:"lock-wait" value-name:"duration" description:"max duration to wait for the lock with the wait policy (default: no limit)"`
+	MaxOutput        outputLimit   `long:"max-output" value-name:"bytes" description:"max bytes of each captured output. the first and last halves are retained, or the first and last bytes of each side with head:tail (default: no limit)"`
+	SpillOutput      bool          `long:"spill-output" description:"stream the outputs to files in a temporary directory and pass their paths to the handlers instead of the outputs"`
+	NoSpillOutput    bool          `long:"no-spill-output" description:"don't spill the outputs even if the config enables it"`
+	KeepOutput       bool          `long:"keep-output" description:"don't remove the output files of --spill-output after running the handlers"`
+	NoKeepOutput     bool          `long:"no-keep-output" description:"remove the output files even if the config enables keepOutput"`
+	HandlerTimeout   time.Duration `long:"handler-timeout" value-name:"duration" description:"kill each handler when it runs longer than the duration (default: no limit)"`
+	HandlerRetry     int           `long:"handler-retry" value-name:"count" description:"retry count of each failed handler with exponential backoff"`
+	SpoolDir         string        `long:"spool-dir" value-name:"/path/to/spool" description:"directory to spool reports which could not be delivered to handlers. they can be re-delivered by 'horenso spool flush'"`
+	Format           string        `long:"format" value-name:"template" description:"Go text/template to render the report for --print-report"`
+	PrintReport      string        `long:"print-report" optional:"yes" optional-value:"stdout" choice:"stdout" choice:"stderr" description:"print the rendered report after the job (default: stdout)"`
+	StateDir         string        `long:"state-dir" value-name:"/path/to/state" description:"directory to store the outcome of the previous run of each job"`
+	HistoryDir       string        `long:"history-dir" value-name:"/path/to/history" description:"directory to record the history of the jobs"`
+	HistoryRetention int           `long:"history-retention" value-name:"count" description:"max number of the history entries of each job (default: 100)"`
+
+	reporters, noticers handlers
+	configSources       configSources
+	signals             *signalTrap
+
+	successExitCodes []int
+	severities       map[int]string
 
 	outStream, errStream io.Writer
 }
@@ -44,6 +73,7 @@ type Report struct {
 	Stderr      string     `json:"stderr"`
 	ExitCode    int        `json:"exitCode"`
 	Signaled    bool       `json:"signaled"`
+	TimedOut    bool       `json:"timedOut"`
 	Result      string     `json:"result"`
 	Hostname    string     `json:"hostname"`
 	Pid         int        `json:"pid,omitempty"`
@@ -51,6 +81,25 @@ type Report struct {
 	EndAt       *time.Time `json:"endAt,omitempty"`
 	SystemTime  float64    `json:"systemTime,omitempty"`
 	UserTime    float64    `json:"userTime,omitempty"`
+
+	DescendantsAlive bool    `json:"descendantsAlive,omitempty"`
+	HorensoSignal    string  `json:"horensoSignal,omitempty"`
+	LockStatus       string  `json:"lockStatus,omitempty"`
+	LockHolderPid    int     `json:"lockHolderPid,omitempty"`
+	LockWait         float64 `json:"lockWait,omitempty"`
+	OutputTruncated  bool    `json:"outputTruncated,omitempty"`
+	StdoutBytes      int64   `json:"stdoutBytes"`
+	StderrBytes      int64   `json:"stderrBytes"`
+	OutputFile       string  `json:"outputFile,omitempty"`
+	StdoutFile       string  `json:"stdoutFile,omitempty"`
+	StderrFile       string  `json:"stderrFile,omitempty"`
+	Status           string  `json:"status,omitempty"`
+	Severity         string  `json:"severity,omitempty"`
+
+	PreviousExitCode    *int       `json:"previousExitCode,omitempty"`
+	PreviousEndAt       *time.Time `json:"previousEndAt,omitempty"`
+	PreviousStatus      string     `json:"previousStatus,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures,omitempty"`
 }
 
 func (ho *horenso) openLog() (io.WriteCloser, error) {
@@ -66,41 +115,210 @@ func (ho *horenso) openLog() (io.WriteCloser, error) {
 }
 
 func (ho *horenso) loadConfig() error {
-	conf := ho.Config
-	if conf == "" {
-		conf = os.Getenv("HORENSO_CONFIG")
+	ho.reporters = newHandlers(ho.Reporter)
+	ho.noticers = newHandlers(ho.Noticer)
+	c := &config{}
+	if files := ho.configFiles(); len(files) > 0 {
+		var (
+			sources configSources
+			err     error
+		)
+		c, sources, err = loadConfigFiles(files, ho.StrictConfig)
+		if err != nil {
+			return err
+		}
+		ho.configSources = sources
+		ho.logf(info, "loaded the config files %q\n%s", files, sources)
 	}
-	if conf == "" {
-		return nil
+	tag := ho.Tag
+	if tag == "" {
+		tag = c.profile("").Tag
 	}
-	c, err := loadConfig(conf)
-	if err != nil {
-		return err
+	if _, ok := c.Jobs[tag]; ok {
+		ho.logf(info, "using the profile of the job %q", tag)
 	}
-	ho.Reporter = append(ho.Reporter, c.Reporter...)
-	ho.Noticer = append(ho.Noticer, c.Noticer...)
-	if !ho.TimeStamp {
-		ho.TimeStamp = c.Timestamp
+	c = c.profile(tag)
+	if c.StrictConfig && !ho.StrictConfig {
+		// load the config again to reject unknown keys
+		ho.StrictConfig = true
+		return ho.loadConfig()
 	}
+	ho.reporters = append(ho.reporters, c.Reporter...)
+	ho.noticers = append(ho.noticers, c.Noticer...)
+	ho.TimeStamp = boolOption(ho.TimeStamp, ho.NoTimeStamp, c.Timestamp)
 	if ho.Tag == "" {
 		ho.Tag = c.Tag
 	}
-	if !ho.OverrideStatus {
-		ho.OverrideStatus = c.OverrideStatus
+	ho.OverrideStatus = boolOption(ho.OverrideStatus, ho.NoOverrideStatus, c.OverrideStatus)
+	if len(ho.Verbose) == 0 && c.Verbose > 0 {
+		ho.Verbose = make([]bool, c.Verbose)
 	}
 	if ho.Logfile == "" {
 		ho.Logfile = c.Logfile
 	}
-	return nil
+	if ho.Timeout == 0 {
+		ho.Timeout = c.Timeout
+	}
+	if ho.KillAfter == 0 {
+		ho.KillAfter = c.KillAfter
+	}
+	if ho.Lock == "" {
+		ho.Lock = c.Lock
+	}
+	if ho.LockPolicy == "" {
+		ho.LockPolicy = c.LockPolicy
+	}
+	if ho.LockWait == 0 {
+		ho.LockWait = c.LockWait
+	}
+	if ho.MaxOutput.isZero() {
+		ho.MaxOutput = c.MaxOutput
+	}
+	ho.SpillOutput = boolOption(ho.SpillOutput, ho.NoSpillOutput, c.SpillOutput)
+	ho.KeepOutput = boolOption(ho.KeepOutput, ho.NoKeepOutput, c.KeepOutput)
+	if ho.HandlerTimeout == 0 {
+		ho.HandlerTimeout = c.HandlerTimeout
+	}
+	if ho.HandlerRetry == 0 {
+		ho.HandlerRetry = c.HandlerRetry
+	}
+	if ho.SpoolDir == "" {
+		ho.SpoolDir = c.SpoolDir
+	}
+	if ho.Format == "" {
+		ho.Format = c.Format
+	}
+	if ho.PrintReport == "" {
+		ho.PrintReport = c.PrintReport
+	}
+	if ho.StateDir == "" {
+		ho.StateDir = c.StateDir
+	}
+	if ho.HistoryDir == "" {
+		ho.HistoryDir = c.HistoryDir
+	}
+	if ho.HistoryRetention == 0 {
+		ho.HistoryRetention = c.HistoryRetention
+	}
+	if err := validateSeverities(c.Severity); err != nil {
+		return err
+	}
+	ho.successExitCodes = c.SuccessExitCodes
+	ho.severities = c.Severity
+	return checkChangeCondition(ho.StateDir, ho.reporters, ho.noticers)
 }
 
-func (ho *horenso) run(args []string) (Report, error) {
-	log.SetPrefix("[horenso] ")
-	log.SetFlags(0)
-	log.SetOutput(ho.errStream)
+// boolOption resolves the boolean option which can be turned on by the flag and off by the
+// negative flag. The flags take precedence over the config.
+func boolOption(on, off bool, conf *bool) bool {
+	switch {
+	case off:
+		return false
+	case on:
+		return true
+	case conf != nil:
+		return *conf
+	}
+	return false
+}
 
-	if err := ho.loadConfig(); err != nil {
-		ho.logf(warn, "failed to load config: %s", err)
+const defaultKillAfter = 10 * time.Second
+
+func (ho *horenso) killAfter() time.Duration {
+	if ho.KillAfter > 0 {
+		return ho.KillAfter
+	}
+	return defaultKillAfter
+}
+
+// watchTimeout terminates the command when it exceeds the timeout and kills it
+// if it is still alive after the grace period. The returned channel is closed
+// when the timeout has been reached.
+func (ho *horenso) watchTimeout(cmd *exec.Cmd, r Report, exited <-chan struct{}) <-chan struct{} {
+	timedOut := make(chan struct{})
+	if ho.Timeout <= 0 {
+		return timedOut
+	}
+	go func() {
+		timer := time.NewTimer(ho.Timeout)
+		defer timer.Stop()
+		select {
+		case <-exited:
+			return
+		case <-timer.C:
+		}
+		close(timedOut)
+		ho.logf(warn, "the command %q timed out after %s. terminating it", r.Command, ho.Timeout)
+		if err := terminate(cmd.Process); err != nil {
+			ho.logf(warn, "failed to terminate the command %q: %s", r.Command, err)
+		}
+		timer.Reset(ho.killAfter())
+		select {
+		case <-exited:
+			return
+		case <-timer.C:
+		}
+		ho.logf(warn, "the command %q is still running. killing it", r.Command)
+		if err := kill(cmd.Process); err != nil {
+			ho.logf(warn, "failed to kill the command %q: %s", r.Command, err)
+		}
+	}()
+	return timedOut
+}
+
+// waitOutputs waits for the outputs of the exited command to be drained. Descendants of the
+// command which keep the outputs open are left running until the timeout of the job or a
+// signal horenso receives. Then they are terminated, killed after the grace period of the
+// timeout, and horenso stops draining the outputs.
+func (ho *horenso) waitOutputs(cmd *exec.Cmd, r Report, eg *errgroup.Group, pipes ...*os.File) error {
+	drained := make(chan error, 1)
+	go func() {
+		err := eg.Wait()
+		if errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+		drained <- err
+	}()
+	var deadline <-chan time.Time
+	if ho.Timeout > 0 {
+		timer := time.NewTimer(time.Until(r.StartAt.Add(ho.Timeout)))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	select {
+	case err := <-drained:
+		return err
+	case <-ho.signals.interrupted():
+		// the signal has been forwarded to the descendants
+	case <-deadline:
+		ho.logf(warn, "descendant processes of the command %q keep the outputs open after the timeout. terminating them", r.Command)
+		if err := terminate(cmd.Process); err != nil {
+			ho.logf(warn, "failed to terminate the descendant processes of the command %q: %s", r.Command, err)
+		}
+	}
+	timer := time.NewTimer(ho.killAfter())
+	defer timer.Stop()
+	select {
+	case err := <-drained:
+		return err
+	case <-timer.C:
+	}
+	ho.logf(warn, "descendant processes of the command %q are still running. killing them", r.Command)
+	if err := kill(cmd.Process); err != nil {
+		ho.logf(warn, "failed to kill the descendant processes of the command %q: %s", r.Command, err)
+	}
+	// the outputs may be held by processes outside of the process group
+	for _, p := range pipes {
+		p.Close()
+	}
+	return <-drained
+}
+
+func (ho *horenso) run(args []string) (Report, error) {
+	ho.setupLog()
+	confErr := ho.loadConfig()
+	if confErr != nil {
+		ho.logf(warn, "failed to load config: %s", confErr)
 	}
 
 	hostname, _ := os.Hostname()
@@ -111,25 +329,70 @@ func (ho *horenso) run(args []string) (Report, error) {
 		ExitCode:    -1,
 		Hostname:    hostname,
 	}
+	if confErr != nil && ho.StrictConfig {
+		r.StartAt = now()
+		err := fmt.Errorf("failed to load config: %s", confErr)
+		return ho.failReport(r, err.Error()), err
+	}
+	ho.signals = ho.trapSignals(r.Command)
+	defer ho.signals.stop()
+	if ho.Lock != "" {
+		f, err := ho.acquireLock(&r)
+		if err != nil {
+			r.StartAt = now()
+			if r.LockStatus == lockSkipped {
+				return ho.skipReport(r), nil
+			}
+			return ho.failReport(r, err.Error()), err
+		}
+		defer f.Close()
+	}
 	cmd := exec.Command(args[0], args[1:]...)
+	setProcessGroup(cmd)
 
-	stdoutPipe, err := cmd.StdoutPipe()
+	// Use os.Pipe instead of cmd.StdoutPipe to be able to wait for the command
+	// before all descendants which inherit the pipes close them.
+	stdoutPipe, stdoutW, err := os.Pipe()
 	if err != nil {
 		return ho.failReport(r, err.Error()), err
 	}
 	defer stdoutPipe.Close()
+	cmd.Stdout = stdoutW
 
-	stderrPipe, err := cmd.StderrPipe()
+	stderrPipe, stderrW, err := os.Pipe()
 	if err != nil {
+		stdoutW.Close()
 		return ho.failReport(r, err.Error()), err
 	}
 	defer stderrPipe.Close()
+	cmd.Stderr = stderrW
 
-	var bufStdout bytes.Buffer
-	var bufStderr bytes.Buffer
-	var bufMerged bytes.Buffer
+	newBuffer := func() *outputBuffer { return newOutputBuffer(ho.MaxOutput) }
+	var stdoutWtr, stderrWtr, mergedWtr io.Writer
+	if ho.SpillOutput {
+		if of, err := createOutputFiles(); err != nil {
+			ho.logf(warn, "failed to create output files: %s", err)
+		} else {
+			defer ho.removeOutputFiles(of)
+			defer of.Close()
+			r.OutputFile = of.output.Name()
+			r.StdoutFile = of.stdout.Name()
+			r.StderrFile = of.stderr.Name()
+			stdoutWtr, stderrWtr, mergedWtr = of.stdout, of.stderr, of.output
+			if ho.MaxOutput.isZero() {
+				// the whole outputs are in the files
+				newBuffer = newDiscardBuffer
+			}
+		}
+	}
+	bufStdout := newBuffer()
+	bufStderr := newBuffer()
+	bufMerged := newBuffer()
 
-	var wtr io.Writer = &bufMerged
+	var wtr io.Writer = bufMerged
+	if mergedWtr != nil {
+		wtr = io.MultiWriter(wtr, mergedWtr)
+	}
 	if ho.Logfile != "" {
 		if f, err := ho.openLog(); err != nil {
 			ho.log(warn, err.Error())
@@ -143,22 +406,44 @@ func (ho *horenso) run(args []string) (Report, error) {
 		defer wc.Close()
 		wtr = wc
 	}
-	stdoutPipe2 := io.TeeReader(stdoutPipe, io.MultiWriter(&bufStdout, wtr))
-	stderrPipe2 := io.TeeReader(stderrPipe, io.MultiWriter(&bufStderr, wtr))
+	var stdoutTee io.Writer = io.MultiWriter(bufStdout, wtr)
+	if stdoutWtr != nil {
+		stdoutTee = io.MultiWriter(bufStdout, stdoutWtr, wtr)
+	}
+	var stderrTee io.Writer = io.MultiWriter(bufStderr, wtr)
+	if stderrWtr != nil {
+		stderrTee = io.MultiWriter(bufStderr, stderrWtr, wtr)
+	}
+	stdoutPipe2 := io.TeeReader(stdoutPipe, stdoutTee)
+	stderrPipe2 := io.TeeReader(stderrPipe, stderrTee)
 
+	select {
+	case <-ho.signals.interrupted():
+		stdoutW.Close()
+		stderrW.Close()
+		r.StartAt = now()
+		err := fmt.Errorf("interrupted before starting the command")
+		return ho.failReport(r, err.Error()), err
+	default:
+	}
 	ho.logf(info, "starting execution of the command %q", r.Command)
 	r.StartAt = now()
 	err = cmd.Start()
+	stdoutW.Close()
+	stderrW.Close()
 	if err != nil {
 		return ho.failReport(r, err.Error()), err
 	}
 	if cmd.Process != nil {
 		r.Pid = cmd.Process.Pid
+		ho.signals.setProcess(cmd.Process)
 	}
 	done := make(chan error)
 	go func(r Report) {
 		done <- ho.runNoticer(r)
 	}(r)
+	exited := make(chan struct{})
+	timedOut := ho.watchTimeout(cmd, r, exited)
 
 	eg := &errgroup.Group{}
 	eg.Go(func() error {
@@ -171,11 +456,16 @@ func (ho *horenso) run(args []string) (Report, error) {
 		_, err := io.Copy(ho.errStream, stderrPipe2)
 		return err
 	})
-	if err := eg.Wait(); err != nil {
-		ho.logf(warn, "something went wrong while executing the command: %s", err)
-	}
 	err = cmd.Wait()
 	r.EndAt = now()
+	close(exited)
+	if descendantsAlive(cmd.Process) {
+		r.DescendantsAlive = true
+		ho.logf(warn, "some descendant processes of the command %q are still alive", r.Command)
+	}
+	if err := ho.waitOutputs(cmd, r, eg, stdoutPipe, stderrPipe); err != nil {
+		ho.logf(warn, "something went wrong while executing the command: %s", err)
+	}
 	es := wrapcommander.ResolveExitStatus(err)
 	r.ExitCode = es.ExitCode()
 	r.Signaled = es.Signaled()
@@ -183,20 +473,47 @@ func (ho *horenso) run(args []string) (Report, error) {
 	if r.Signaled {
 		r.Result = fmt.Sprintf("command died with signal: %d", r.ExitCode&127)
 	}
+	select {
+	case <-timedOut:
+		r.TimedOut = true
+		r.Result = fmt.Sprintf("command timed out after %s: %s", ho.Timeout, r.Result)
+	default:
+	}
+	ho.recordSignal(&r)
 	ho.logf(info, "the command %q finished: %s", r.Command, r.Result)
 	r.Stdout = bufStdout.String()
 	r.Stderr = bufStderr.String()
 	r.Output = bufMerged.String()
+	r.StdoutBytes = bufStdout.Len()
+	r.StderrBytes = bufStderr.Len()
+	r.OutputTruncated = bufStdout.Truncated() || bufStderr.Truncated() || bufMerged.Truncated()
 	if p := cmd.ProcessState; p != nil {
 		r.UserTime = float64(p.UserTime()) / float64(time.Second)
 		r.SystemTime = float64(p.SystemTime()) / float64(time.Second)
 	}
+	ho.completeReport(&r)
 	ho.runReporter(r)
 	<-done
 	ho.logf(info, "all processes are completed for the job %q", r.Command)
 	return r, nil
 }
 
+func (ho *horenso) removeOutputFiles(of *outputFiles) {
+	if ho.KeepOutput {
+		ho.logf(info, "the output files are kept in %q", of.dir)
+		return
+	}
+	if err := of.remove(); err != nil {
+		ho.logf(warn, "failed to remove the output files in %q: %s", of.dir, err)
+	}
+}
+
+func (ho *horenso) setupLog() {
+	log.SetPrefix("[horenso] ")
+	log.SetFlags(0)
+	log.SetOutput(ho.errStream)
+}
+
 func now() *time.Time {
 	now := time.Now()
 	return &now
@@ -214,8 +531,22 @@ Version: %s (rev: %s/%s)`, version, revision, runtime.Version())
 	return p, ho, rest, err
 }
 
+var subcommands = map[string]func(args []string) int{
+	"spool":        runSpool,
+	"history":      runHistory,
+	"last":         runLast,
+	"replay":       runReplay,
+	"test-handler": runTestHandler,
+	"config":       runConfig,
+}
+
 // Run the horenso
 func Run(args []string) int {
+	if len(args) > 0 {
+		if sub, ok := subcommands[args[0]]; ok {
+			return sub(args[1:])
+		}
+	}
 	p, ho, cmdArgs, err := parseArgs(args)
 	if err != nil || len(cmdArgs) < 1 {
 		if ferr, ok := err.(*flags.Error); !ok || ferr.Type != flags.ErrHelp {
@@ -224,18 +555,64 @@ func Run(args []string) int {
 		return 2
 	}
 	r, err := ho.run(cmdArgs)
+	ho.printReport(r)
 	if err != nil {
 		return wrapcommander.ResolveExitCode(err)
 	}
-	if ho.OverrideStatus {
+	if ho.OverrideStatus || r.LockStatus == lockSkipped {
 		return 0
 	}
 	return r.ExitCode
 }
 
+// completeReport resolves the status of the finished job and records it
+func (ho *horenso) completeReport(r *Report) {
+	ho.resolveStatus(r)
+	ho.updateState(r)
+	if ho.HistoryDir != "" {
+		if err := ho.recordHistory(*r); err != nil {
+			ho.logf(warn, "failed to record the history of the job: %s", err)
+		}
+	}
+}
+
+func (ho *horenso) printReport(r Report) {
+	var w io.Writer
+	switch ho.PrintReport {
+	case "":
+		return
+	case "stderr":
+		w = ho.errStream
+	default:
+		w = ho.outStream
+	}
+	format := ho.Format
+	if format == "" {
+		format = defaultReportFormat
+	}
+	out, err := renderTemplate("report", format, r)
+	if err != nil {
+		ho.logf(warn, "failed to render the report: %s", err)
+		return
+	}
+	io.WriteString(w, out)
+}
+
 func (ho *horenso) failReport(r Report, errStr string) Report {
 	r.Result = fmt.Sprintf("failed to execute the command: %s", errStr)
 	ho.logf(warn, "failed to execute the command %q: %s", r.Command, errStr)
+	return ho.reportWithoutRunning(r)
+}
+
+func (ho *horenso) skipReport(r Report) Report {
+	r.Result = fmt.Sprintf("skipped: lock held by pid %d", r.LockHolderPid)
+	ho.logf(warn, "skipped the command %q: lock %q held by pid %d", r.Command, ho.Lock, r.LockHolderPid)
+	return ho.reportWithoutRunning(r)
+}
+
+func (ho *horenso) reportWithoutRunning(r Report) Report {
+	ho.recordSignal(&r)
+	ho.completeReport(&r)
 	done := make(chan error)
 	go func() {
 		done <- ho.runNoticer(r)
@@ -245,6 +622,14 @@ func (ho *horenso) failReport(r Report, errStr string) Report {
 	return r
 }
 
+// recordSignal records the signal horenso received in the report
+func (ho *horenso) recordSignal(r *Report) {
+	if sig := ho.signals.received(); sig != nil {
+		r.HorensoSignal = sig.String()
+		r.Result = fmt.Sprintf("horenso received signal %q: %s", sig, r.Result)
+	}
+}
+
 func (ho *horenso) appendOut(base, out string) string {
 	out = strings.TrimSpace(out)
 	if out == "" {
@@ -268,27 +653,84 @@ func (ho *horenso) splitHandlerCmdStr(cmdStr string) ([]string, error) {
 	}
 }
 
-func (ho *horenso) runHandler(cmdStr string, json []byte) error {
-	ho.logf(info, "starting to run the handler %q", cmdStr)
-	args, err := ho.splitHandlerCmdStr(cmdStr)
-	if err != nil || len(args) < 1 {
-		ho.logf(warn, "failed to run the handler %q: invalid handler arguments", cmdStr)
-		return fmt.Errorf("invalid handler: %q", cmdStr)
+func (ho *horenso) handlerTimeout(h handler) time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
 	}
-	cmd := exec.Command(args[0], args[1:]...)
-	stdinPipe, _ := cmd.StdinPipe()
-	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
-	if err := cmd.Start(); err != nil {
-		stdinPipe.Close()
-		logoutput := fmt.Sprintf("failed to run the handler %q: %s", cmdStr, err)
-		ho.log(warn, ho.appendOut(logoutput, b.String()))
+	return ho.HandlerTimeout
+}
+
+const (
+	defaultRetryInterval = time.Second
+	maxRetryWait         = time.Minute
+)
+
+// retryWait returns the wait before the i-th retry, which doubles from the interval up to
+// maxRetryWait or the interval if it is longer
+func retryWait(interval time.Duration, i int) time.Duration {
+	max := maxRetryWait
+	if interval > max {
+		max = interval
+	}
+	wait := interval
+	for ; i > 0 && wait < max; i-- {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
+
+// deliver runs the handler and retries it with exponential backoff when it
+// fails. The report is spooled when all the attempts failed.
+func (ho *horenso) deliver(h handler, json []byte, phase string) error {
+	retry := ho.HandlerRetry
+	if h.Retry > 0 {
+		retry = h.Retry
+	}
+	interval := defaultRetryInterval
+	if h.RetryInterval > 0 {
+		interval = h.RetryInterval
+	}
+	var err error
+	for i := 0; ; i++ {
+		if err = ho.runHandler(h, json, phase); err == nil || i >= retry {
+			break
+		}
+		wait := retryWait(interval, i)
+		ho.logf(warn, "retrying the handler %q in %s (%d/%d)", h.Command, wait, i+1, retry)
+		time.Sleep(wait)
+	}
+	if err != nil && ho.SpoolDir != "" {
+		if serr := ho.spool(h, json, phase); serr != nil {
+			ho.logf(warn, "failed to spool the report for the handler %q: %s", h.Command, serr)
+		} else {
+			ho.logf(warn, "spooled the report for the handler %q into %q", h.Command, ho.SpoolDir)
+		}
+	}
+	return err
+}
+
+func (ho *horenso) runHandler(h handler, json []byte, phase string) error {
+	h, err := h.expand()
+	if err != nil {
+		ho.logf(warn, "failed to expand the handler %q: %s", h.name(), err)
 		return err
 	}
-	stdinPipe.Write(json)
-	stdinPipe.Close()
-	err = cmd.Wait()
+	switch {
+	case isWebhook(h):
+		return ho.runWebhook(h, json)
+	case isSlack(h):
+		return ho.runSlack(h, json)
+	case isMail(h):
+		return ho.runMail(h, json)
+	case isSyslog(h), isJournald(h):
+		return ho.runSyslog(h, json)
+	}
+	cmdStr := h.name()
+	ho.logf(info, "starting to run the handler %q", cmdStr)
+	out, err := ho.execHandler(h, json, phase)
 	if err != nil || ho.logLevel() >= info {
 		var logoutput string
 		lv := info
@@ -298,35 +740,106 @@ func (ho *horenso) runHandler(cmdStr string, json []byte) error {
 		} else {
 			logoutput = fmt.Sprintf("finished to run the handler %q", cmdStr)
 		}
-		ho.log(lv, ho.appendOut(logoutput, b.String()))
+		ho.log(lv, ho.appendOut(logoutput, string(out)))
 	}
 	return err
 }
 
-func (ho *horenso) runHandlers(handlers []string, json []byte) error {
+// execHandler executes the handler command with the report and returns its combined output
+func (ho *horenso) execHandler(h handler, json []byte, phase string) ([]byte, error) {
+	args, err := ho.splitHandlerCmdStr(h.Command)
+	if err != nil || len(args) < 1 {
+		return nil, fmt.Errorf("invalid handler arguments")
+	}
+	input := json
+	if h.Format != "" {
+		if input, err = renderReport(h.Format, json); err != nil {
+			return nil, fmt.Errorf("failed to render the report: %s", err)
+		}
+	}
+	env, cleanup, err := handlerEnv(json, phase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare the environment: %s", err)
+	}
+	defer cleanup()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = env
+	setProcessGroup(cmd)
+	stdinPipe, _ := cmd.StdinPipe()
+	// Use os.Pipe not to wait for the descendants holding the output open after the timeout
+	outPipe, outW, err := os.Pipe()
+	if err != nil {
+		stdinPipe.Close()
+		return nil, err
+	}
+	defer outPipe.Close()
+	cmd.Stdout = outW
+	cmd.Stderr = outW
+	err = cmd.Start()
+	outW.Close()
+	if err != nil {
+		stdinPipe.Close()
+		return nil, err
+	}
+	var b bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&b, outPipe)
+		close(copied)
+	}()
+	killed := make(chan struct{})
+	if timeout := ho.handlerTimeout(h); timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			ho.logf(warn, "the handler %q timed out after %s. killing it", h.name(), timeout)
+			if err := kill(cmd.Process); err != nil {
+				ho.logf(warn, "failed to kill the handler %q: %s", h.name(), err)
+			}
+			close(killed)
+		})
+		defer timer.Stop()
+	}
+	stdinPipe.Write(input)
+	stdinPipe.Close()
+	err = cmd.Wait()
+	select {
+	case <-copied:
+	case <-killed:
+		// the output may be held by the descendants which are not killed
+		outPipe.Close()
+		<-copied
+	}
+	return b.Bytes(), err
+}
+
+func (ho *horenso) runHandlers(hs handlers, r Report, phase string) error {
+	json, _ := json.Marshal(r)
 	eg := &errgroup.Group{}
-	for _, handler := range handlers {
+	for _, handler := range hs {
 		h := handler
+		if !h.On.match(r) {
+			ho.logf(info, "skipped the handler %q: the condition %q is not satisfied", h.Command, h.On)
+			continue
+		}
 		eg.Go(func() error {
-			return ho.runHandler(h, json)
+			return ho.deliver(h, json, phase)
 		})
 	}
 	return eg.Wait()
 }
 
 func (ho *horenso) runNoticer(r Report) error {
-	if len(ho.Noticer) < 1 {
+	if len(ho.noticers) < 1 {
 		return nil
 	}
 	ho.logf(info, "starting to run the noticers")
 	defer ho.logf(info, "finished to run the noticers")
-	json, _ := json.Marshal(r)
-	return ho.runHandlers(ho.Noticer, json)
+	return ho.runHandlers(ho.noticers, r, phaseNotice)
 }
 
 func (ho *horenso) runReporter(r Report) error {
+	// a signal during the reporters terminates horenso
+	ho.signals.stop()
 	ho.logf(info, "starting to run the reporters")
 	defer ho.logf(info, "finished to run the reporters")
-	json, _ := json.Marshal(r)
-	return ho.runHandlers(ho.Reporter, json)
+	return ho.runHandlers(ho.reporters, r, phaseReport)
 }
@@ -4,13 +4,24 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
 
+func TestMain(m *testing.M) {
+	// not to load the config files on the host
+	defaultConfigFiles = func() []string { return nil }
+	os.Exit(m.Run())
+}
+
 func temp() string {
 	f, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -64,6 +75,9 @@ func TestRun(t *testing.T) {
 	if r.ExitCode != 0 {
 		t.Errorf("exit code should be 0 but: %d", r.ExitCode)
 	}
+	if r.Status != "success" || r.Severity != "ok" {
+		t.Errorf("status should be success/ok but: %s/%s", r.Status, r.Severity)
+	}
 
 	expect := "1\n2\n3\n"
 	if r.Output != expect {
@@ -245,7 +259,7 @@ func TestRun_config(t *testing.T) {
 		t.Errorf("err should be nil, but: %s", err)
 	}
 
-	if !reflect.DeepEqual(ho.Reporter, []string{"hhh", "hoge", "fuga"}) {
+	if !reflect.DeepEqual(ho.reporters.commands(), []string{"hhh", "hoge", "fuga"}) {
 		t.Errorf("something went wrong")
 	}
 
@@ -265,6 +279,551 @@ func TestRun_config(t *testing.T) {
 	}
 }
 
+func TestRun_timeout(t *testing.T) {
+	fname := temp()
+	defer os.RemoveAll(fname)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter",
+		"go run testdata/reporter.go " + fname,
+		"--timeout", "3s",
+		"--kill-after", "1s",
+		"--",
+		"go", "run", "testdata/run_sleep.go", "5s",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if !r.TimedOut {
+		t.Errorf("TimedOut should be true")
+	}
+	if !strings.HasPrefix(r.Result, "command timed out after 3s: ") {
+		t.Errorf("unexpected result: %s", r.Result)
+	}
+	if r.ExitCode == 0 {
+		t.Errorf("exit code shouldn't be 0")
+	}
+
+	rr := parseReport(fname)
+	if !deepEqual(r, rr) {
+		t.Errorf("something went wrong. expect: %#v, got: %#v", r, rr)
+	}
+}
+
+func TestRun_descendants(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported on windows")
+	}
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--timeout", "1s",
+		"--",
+		"sh", "-c", "sleep 30 & echo 1",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	start := time.Now()
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if r.ExitCode != 0 {
+		t.Errorf("exit code should be 0 but: %d", r.ExitCode)
+	}
+	if !r.DescendantsAlive {
+		t.Errorf("DescendantsAlive should be true")
+	}
+	if r.TimedOut {
+		t.Errorf("TimedOut should be false because the command exited before the timeout")
+	}
+	if r.Result != "command exited with code: 0" {
+		t.Errorf("unexpected result: %s", r.Result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("descendants keeping the outputs open should be terminated but took %s", elapsed)
+	}
+}
+
+func TestRun_descendantsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported on windows")
+	}
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--",
+		"sh", "-c", "(sleep 1; echo late) & echo early",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if !r.DescendantsAlive {
+		t.Errorf("DescendantsAlive should be true")
+	}
+	if r.Stdout != "early\nlate\n" {
+		t.Errorf("the output of the descendants should be captured without the timeout but: %q", r.Stdout)
+	}
+}
+
+func TestRun_signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+	fname := temp()
+	defer os.RemoveAll(fname)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter",
+		"go run testdata/reporter.go " + fname,
+		"--",
+		"go", "run", "testdata/run_sleep.go", "30s",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	go func() {
+		time.Sleep(2 * time.Second)
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(syscall.SIGHUP)
+	}()
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if r.HorensoSignal != syscall.SIGHUP.String() {
+		t.Errorf("HorensoSignal should be %q but: %q", syscall.SIGHUP, r.HorensoSignal)
+	}
+	if r.ExitCode == 0 {
+		t.Errorf("exit code shouldn't be 0")
+	}
+
+	rr := parseReport(fname)
+	if !deepEqual(r, rr) {
+		t.Errorf("something went wrong. expect: %#v, got: %#v", r, rr)
+	}
+}
+
+func TestRun_signalWhileDraining(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--",
+		"sh", "-c", "sleep 10 & echo 1",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	go func() {
+		time.Sleep(time.Second)
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(syscall.SIGHUP)
+	}()
+	start := time.Now()
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if r.HorensoSignal != syscall.SIGHUP.String() {
+		t.Errorf("HorensoSignal should be %q but: %q", syscall.SIGHUP, r.HorensoSignal)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the signal should be forwarded to the descendants but took %s", elapsed)
+	}
+}
+
+func TestRun_signalWhileLocking(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+	lockfile := temp()
+	fname := temp()
+	defer func() {
+		for _, f := range []string{lockfile, fname} {
+			os.RemoveAll(f)
+		}
+	}()
+	f, err := os.OpenFile(lockfile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := tryLock(f); err != nil {
+		t.Fatalf("failed to lock: %s", err)
+	}
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter", "go run testdata/reporter.go " + fname,
+		"--lock", lockfile,
+		"--lock-policy", "wait",
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	go func() {
+		time.Sleep(time.Second)
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(syscall.SIGHUP)
+	}()
+	r, err := ho.run(cmdArgs)
+	if err == nil {
+		t.Errorf("err should not be nil")
+	}
+	if r.HorensoSignal != syscall.SIGHUP.String() || r.LockStatus != lockFailed || r.Pid != 0 {
+		t.Errorf("the interrupted lock wait should be reported but: %#v", r)
+	}
+	if rr := parseReport(fname); rr.HorensoSignal != r.HorensoSignal {
+		t.Errorf("the reporter should receive the report but: %#v", rr)
+	}
+}
+
+func TestRun_signalWhileReporting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+	dir, err := ioutil.TempDir("", "horenso-signal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "horenso")
+	if out, err := exec.Command("go", "build", "-o", bin, "./cmd/horenso").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build horenso: %s\n%s", err, out)
+	}
+
+	// the reporter sends SIGTERM to horenso which should not be trapped any more
+	start := time.Now()
+	cmd := exec.Command(bin, "--reporter", `sh -c 'kill -TERM $PPID; sleep 10'`, "--", "true")
+	cmd.Env = append(os.Environ(), "HORENSO_CONFIG=")
+	err = cmd.Run()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("horenso should be terminated while running the reporter but took: %s", elapsed)
+	}
+	if err == nil || cmd.ProcessState.Sys().(syscall.WaitStatus).Signal() != syscall.SIGTERM {
+		t.Errorf("horenso should die with SIGTERM but: %v", err)
+	}
+}
+
+func TestRun_strictConfig(t *testing.T) {
+	fname := temp()
+	conf := temp()
+	defer func() {
+		for _, f := range []string{fname, conf} {
+			os.RemoveAll(f)
+		}
+	}()
+	ioutil.WriteFile(conf, []byte("reporters: typo\n"), 0644)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter",
+		"go run testdata/reporter.go " + fname,
+		"--config", conf,
+		"--strict-config",
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+	r, err := ho.run(cmdArgs)
+	if err == nil {
+		t.Errorf("err should not be nil")
+	}
+	if r.ExitCode != -1 || !strings.HasPrefix(r.Result, "failed to execute the command: failed to load config:") {
+		t.Errorf("the command should not be run but: %#v", r)
+	}
+	rr := parseReport(fname)
+	if rr.Result != r.Result {
+		t.Errorf("the reporter should receive the failure but: %#v", rr)
+	}
+}
+
+func TestRun_lock(t *testing.T) {
+	lockfile := temp()
+	fname := temp()
+	defer func() {
+		for _, f := range []string{lockfile, fname} {
+			os.RemoveAll(f)
+		}
+	}()
+	run := func(policy string) (Report, error) {
+		_, ho, cmdArgs, err := parseArgs([]string{
+			"--reporter",
+			"go run testdata/reporter.go " + fname,
+			"--lock", lockfile,
+			"--lock-policy", policy,
+			"--lock-wait", "300ms",
+			"--",
+			"go", "run", "testdata/run.go",
+		})
+		if err != nil {
+			t.Errorf("err should be nil but: %s", err)
+		}
+		ho.errStream = ioutil.Discard
+		ho.outStream = ioutil.Discard
+		return ho.run(cmdArgs)
+	}
+
+	f, err := os.OpenFile(lockfile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tryLock(f); err != nil {
+		t.Fatalf("failed to lock: %s", err)
+	}
+	f.WriteString("12345\n")
+
+	r, err := run("skip")
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if r.LockStatus != "skipped" {
+		t.Errorf("LockStatus should be skipped but: %s", r.LockStatus)
+	}
+	if r.Result != "skipped: lock held by pid 12345" {
+		t.Errorf("unexpected result: %s", r.Result)
+	}
+	if r.ExitCode != -1 {
+		t.Errorf("exit code should be -1 but: %d", r.ExitCode)
+	}
+	rr := parseReport(fname)
+	if !deepEqual(r, rr) {
+		t.Errorf("something went wrong. expect: %#v, got: %#v", r, rr)
+	}
+
+	for _, policy := range []string{"wait", "fail"} {
+		r, err = run(policy)
+		if err == nil {
+			t.Errorf("err shouldn't be nil")
+		}
+		if r.LockStatus != "failed" {
+			t.Errorf("LockStatus should be failed but: %s", r.LockStatus)
+		}
+		if r.LockHolderPid != 12345 {
+			t.Errorf("LockHolderPid should be 12345 but: %d", r.LockHolderPid)
+		}
+	}
+
+	f.Close()
+	r, err = run("fail")
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if r.LockStatus != "acquired" {
+		t.Errorf("LockStatus should be acquired but: %s", r.LockStatus)
+	}
+	if r.ExitCode != 0 {
+		t.Errorf("exit code should be 0 but: %d", r.ExitCode)
+	}
+}
+
+func TestRun_spillOutput(t *testing.T) {
+	fname := temp()
+	defer os.RemoveAll(fname)
+	run := func(keep bool) Report {
+		args := []string{
+			"--reporter",
+			"go run testdata/reporter.go " + fname,
+			"--spill-output",
+		}
+		if keep {
+			args = append(args, "--keep-output")
+		}
+		args = append(args, "--", "go", "run", "testdata/run.go")
+		_, ho, cmdArgs, err := parseArgs(args)
+		if err != nil {
+			t.Errorf("err should be nil but: %s", err)
+		}
+		ho.errStream = ioutil.Discard
+		ho.outStream = ioutil.Discard
+
+		r, err := ho.run(cmdArgs)
+		if err != nil {
+			t.Errorf("err should be nil but: %s", err)
+		}
+		return r
+	}
+
+	r := run(true)
+	defer os.RemoveAll(filepath.Dir(r.OutputFile))
+	if r.Output != "" || r.Stdout != "" || r.Stderr != "" {
+		t.Errorf("outputs should be empty but: %#v", r)
+	}
+	if r.OutputTruncated {
+		t.Errorf("OutputTruncated should be false")
+	}
+	if r.StdoutBytes != 6 {
+		t.Errorf("StdoutBytes should be 6 but: %d", r.StdoutBytes)
+	}
+	expect := map[string]string{
+		r.OutputFile: "1\n2\n3\n",
+		r.StdoutFile: "1\n2\n3\n",
+		r.StderrFile: "",
+	}
+	for f, e := range expect {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Errorf("failed to read %s: %s", f, err)
+		}
+		if string(b) != e {
+			t.Errorf("content of %s should be %q but: %q", f, e, string(b))
+		}
+	}
+	rr := parseReport(fname)
+	if !deepEqual(r, rr) {
+		t.Errorf("something went wrong. expect: %#v, got: %#v", r, rr)
+	}
+
+	r = run(false)
+	if r.OutputFile == "" {
+		t.Errorf("OutputFile shouldn't be empty")
+	}
+	if _, err := os.Stat(filepath.Dir(r.OutputFile)); !os.IsNotExist(err) {
+		t.Errorf("output directory should be removed but: %v", err)
+	}
+}
+
+func TestRun_handlerEnv(t *testing.T) {
+	noticeEnv := temp()
+	reportEnv := temp()
+	defer func() {
+		for _, f := range []string{noticeEnv, reportEnv} {
+			os.RemoveAll(f)
+		}
+	}()
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--noticer", "go run testdata/env.go " + noticeEnv,
+		"--reporter", "go run testdata/env.go " + reportEnv,
+		"--tag", "env-test",
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+
+	load := func(file string) map[string]string {
+		env := map[string]string{}
+		b, _ := ioutil.ReadFile(file)
+		if err := json.Unmarshal(b, &env); err != nil {
+			t.Fatalf("failed to parse the environment: %s", err)
+		}
+		return env
+	}
+	env := load(reportEnv)
+	expect := map[string]string{
+		"HORENSO_TAG":       "env-test",
+		"HORENSO_EXIT_CODE": "0",
+		"HORENSO_RESULT":    "command exited with code: 0",
+		"HORENSO_COMMAND":   "go run testdata/run.go",
+		"HORENSO_PHASE":     "report",
+		"HORENSO_START_AT":  r.StartAt.Format(time.RFC3339),
+	}
+	for k, v := range expect {
+		if env[k] != v {
+			t.Errorf("%s should be %q but: %q", k, v, env[k])
+		}
+	}
+	if d, err := strconv.ParseFloat(env["HORENSO_DURATION"], 64); err != nil || d <= 0 {
+		t.Errorf("HORENSO_DURATION should be positive seconds but: %q", env["HORENSO_DURATION"])
+	}
+	var rr Report
+	if err := json.Unmarshal([]byte(env["report"]), &rr); err != nil || rr.Output != "1\n2\n3\n" {
+		t.Errorf("HORENSO_REPORT_FILE should have the report but: %q", env["report"])
+	}
+	if _, err := os.Stat(env["HORENSO_REPORT_FILE"]); !os.IsNotExist(err) {
+		t.Errorf("the report file should be removed after the handler")
+	}
+
+	env = load(noticeEnv)
+	if env["HORENSO_PHASE"] != "notice" || env["HORENSO_EXIT_CODE"] != "-1" || env["HORENSO_DURATION"] != "0" {
+		t.Errorf("unexpected environment of the noticer: %v", env)
+	}
+}
+
+func TestRun_handlerTimeout(t *testing.T) {
+	fname := temp()
+	defer os.RemoveAll(fname)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter",
+		"go run testdata/run_sleep.go 30s",
+		"--reporter",
+		"go run testdata/reporter.go " + fname,
+		"--handler-timeout", "2s",
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	start := time.Now()
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Second {
+		t.Errorf("the handler should be killed but took %s", elapsed)
+	}
+	rr := parseReport(fname)
+	if !deepEqual(r, rr) {
+		t.Errorf("something went wrong. expect: %#v, got: %#v", r, rr)
+	}
+}
+
+func TestExecHandler_timeoutDescendants(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not available")
+	}
+	ho := &horenso{}
+	// the descendant in another session is not killed with the handler like the
+	// grandchildren on windows
+	h := handler{Command: "sh -c 'setsid sleep 30 & echo started; sleep 30'", Timeout: time.Second}
+	start := time.Now()
+	out, err := ho.execHandler(h, []byte(`{"command":"echo"}`), phaseReport)
+	if err == nil {
+		t.Errorf("err should not be nil")
+	}
+	if string(out) != "started\n" {
+		t.Errorf("the output should be kept but: %q", out)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("the handler should be bounded by the timeout but took %s", elapsed)
+	}
+}
+
 func TestRunHugeOutput(t *testing.T) {
 	fname := temp()
 	defer os.RemoveAll(fname)
@@ -316,6 +875,77 @@ func TestRunHugeOutput(t *testing.T) {
 	}
 }
 
+func TestRunHugeOutput_maxOutput(t *testing.T) {
+	fname := temp()
+	defer os.RemoveAll(fname)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter",
+		"go run testdata/reporter.go " + fname,
+		"--max-output", "128",
+		"--",
+		"go", "run", "testdata/run_hugeoutput.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+
+	if !r.OutputTruncated {
+		t.Errorf("OutputTruncated should be true")
+	}
+	var expectBytes int64 = 64*1024 + 1
+	if r.StdoutBytes != expectBytes {
+		t.Errorf("StdoutBytes should be %d but: %d", expectBytes, r.StdoutBytes)
+	}
+	if r.StderrBytes != 0 {
+		t.Errorf("StderrBytes should be 0 but: %d", r.StderrBytes)
+	}
+	expect := strings.Repeat("x", 63) + "\n" +
+		"\n... (65409 bytes truncated) ...\n" +
+		strings.Repeat("x", 62) + "\n\n"
+	if r.Stdout != expect {
+		t.Errorf("stdout should be %q but: %q", expect, r.Stdout)
+	}
+	if r.Output != expect {
+		t.Errorf("output should be %q but: %q", expect, r.Output)
+	}
+
+	rr := parseReport(fname)
+	if !deepEqual(r, rr) {
+		t.Errorf("something went wrong. expect: %#v, got: %#v", r, rr)
+	}
+}
+
+func TestRunHugeOutput_maxOutputHeadTail(t *testing.T) {
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--max-output", "64:16",
+		"--",
+		"go", "run", "testdata/run_hugeoutput.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	expect := strings.Repeat("x", 63) + "\n" +
+		"\n... (65457 bytes truncated) ...\n" +
+		strings.Repeat("x", 14) + "\n\n"
+	if r.Stdout != expect || !r.OutputTruncated {
+		t.Errorf("stdout should be %q but: %q", expect, r.Stdout)
+	}
+}
+
 func TestSplitHandlerCmdStr(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -385,6 +1015,19 @@ func deepEqual(r1, r2 Report) bool {
 		r1.Pid == r2.Pid &&
 		r1.Hostname == r2.Hostname &&
 		r1.Signaled == r2.Signaled &&
+		r1.TimedOut == r2.TimedOut &&
+		r1.DescendantsAlive == r2.DescendantsAlive &&
+		r1.HorensoSignal == r2.HorensoSignal &&
+		r1.LockStatus == r2.LockStatus &&
+		r1.LockHolderPid == r2.LockHolderPid &&
+		r1.OutputTruncated == r2.OutputTruncated &&
+		r1.StdoutBytes == r2.StdoutBytes &&
+		r1.StderrBytes == r2.StderrBytes &&
+		r1.OutputFile == r2.OutputFile &&
+		r1.StdoutFile == r2.StdoutFile &&
+		r1.StderrFile == r2.StderrFile &&
+		r1.Status == r2.Status &&
+		r1.Severity == r2.Severity &&
 		equalTimePtr(r1.StartAt, r2.StartAt) &&
 		equalTimePtr(r1.EndAt, r2.EndAt)
 }
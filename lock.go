@@ -0,0 +1,107 @@
+package horenso
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockPolicySkip = "skip"
+	lockPolicyWait = "wait"
+	lockPolicyFail = "fail"
+)
+
+const (
+	lockAcquired = "acquired"
+	lockSkipped  = "skipped"
+	lockFailed   = "failed"
+)
+
+const lockPollInterval = 100 * time.Millisecond
+
+var (
+	errLockHeld    = errors.New("lock is held by another process")
+	errInterrupted = errors.New("interrupted while waiting for the lock")
+)
+
+func (ho *horenso) lockPolicy() string {
+	if ho.LockPolicy == "" {
+		return lockPolicySkip
+	}
+	return ho.LockPolicy
+}
+
+// acquireLock takes an exclusive lock of ho.Lock according to the lock policy
+// and records the outcome in the report. The returned file should be closed
+// to release the lock.
+func (ho *horenso) acquireLock(r *Report) (*os.File, error) {
+	policy := ho.lockPolicy()
+	switch policy {
+	case lockPolicySkip, lockPolicyWait, lockPolicyFail:
+	default:
+		r.LockStatus = lockFailed
+		return nil, fmt.Errorf("invalid lock policy: %q", policy)
+	}
+	f, err := os.OpenFile(ho.Lock, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		r.LockStatus = lockFailed
+		return nil, fmt.Errorf("failed to open lock file %q: %s", ho.Lock, err)
+	}
+	ho.logf(info, "acquiring the lock %q", ho.Lock)
+	start := time.Now()
+	for {
+		err = tryLock(f)
+		if err != errLockHeld || policy != lockPolicyWait {
+			break
+		}
+		if ho.LockWait > 0 && time.Since(start) >= ho.LockWait {
+			break
+		}
+		select {
+		case <-time.After(lockPollInterval):
+			continue
+		case <-ho.signals.interrupted():
+			err = errInterrupted
+		}
+		break
+	}
+	if policy == lockPolicyWait {
+		r.LockWait = float64(time.Since(start)) / float64(time.Second)
+	}
+	if err != nil {
+		f.Close()
+		if err != errLockHeld {
+			r.LockStatus = lockFailed
+			return nil, fmt.Errorf("failed to lock %q: %s", ho.Lock, err)
+		}
+		r.LockHolderPid = readLockHolder(ho.Lock)
+		if policy == lockPolicySkip {
+			r.LockStatus = lockSkipped
+			return nil, err
+		}
+		r.LockStatus = lockFailed
+		if policy == lockPolicyWait {
+			return nil, fmt.Errorf("lock %q held by pid %d was not released within %s", ho.Lock, r.LockHolderPid, ho.LockWait)
+		}
+		return nil, fmt.Errorf("lock %q is held by pid %d", ho.Lock, r.LockHolderPid)
+	}
+	r.LockStatus = lockAcquired
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return f, nil
+}
+
+func readLockHolder(file string) int {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid
+}
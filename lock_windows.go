@@ -0,0 +1,19 @@
+package horenso
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLock locks a byte far beyond the end of the file so that other processes
+// can still read the pid written in the lock file.
+func tryLock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: ^uint32(0), OffsetHigh: 0x7fffffff}
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errLockHeld
+	}
+	return err
+}
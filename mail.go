@@ -0,0 +1,241 @@
+package horenso
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const mailScheme = "mail+"
+
+const (
+	defaultMailSubject     = `[horenso] {{if .Status}}{{.Status}}: {{end}}{{if .Tag}}{{.Tag}}{{else}}{{.Command}}{{end}} on {{.Hostname}}`
+	defaultMailAttachLimit = 64 * 1024
+	defaultMailTimeout     = 30 * time.Second
+)
+
+// mailOptions are options for the mail reporter
+type mailOptions struct {
+	From        string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To          []string `yaml:"to,omitempty" json:"to,omitempty"`
+	StartTLS    bool     `yaml:"startTLS,omitempty" json:"startTLS,omitempty"`
+	AuthFile    string   `yaml:"authFile,omitempty" json:"authFile,omitempty"`
+	Subject     string   `yaml:"subject,omitempty" json:"subject,omitempty"`
+	AttachLimit int      `yaml:"attachLimit,omitempty" json:"attachLimit,omitempty"`
+}
+
+func isMail(h handler) bool {
+	return strings.HasPrefix(h.Command, mailScheme)
+}
+
+// mailAuth reads "username:password" from the auth file
+func mailAuth(file string) (user, pass string, err error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the auth file: %s", err)
+	}
+	line := strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("auth file should be in the form of username:password: %s", file)
+	}
+	return line[:i], line[i+1:], nil
+}
+
+func mailSummary(r Report) string {
+	var b strings.Builder
+	add := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(&b, "%-10s %s\n", k+":", v)
+		}
+	}
+	add("Command", r.Command)
+	add("Tag", r.Tag)
+	add("Host", r.Hostname)
+	add("Result", r.Result)
+	add("Status", r.Status)
+	add("Exit Code", fmt.Sprint(r.ExitCode))
+	if r.StartAt != nil {
+		add("Start At", r.StartAt.Format(time.RFC3339))
+	}
+	if r.EndAt != nil {
+		add("End At", r.EndAt.Format(time.RFC3339))
+	}
+	if d := reportDuration(r); d > 0 {
+		add("Duration", d.String())
+	}
+	return b.String()
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, s); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// buildMail builds the message of the report. The output is attached when it
+// exceeds the attach limit.
+func buildMail(h handler, r Report, date time.Time) ([]byte, error) {
+	subjTmpl := h.Subject
+	if subjTmpl == "" {
+		subjTmpl = defaultMailSubject
+	}
+	subject, err := renderTemplate("subject", subjTmpl, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the subject: %s", err)
+	}
+	limit := h.AttachLimit
+	if limit <= 0 {
+		limit = defaultMailAttachLimit
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", h.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(h.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	body := mailSummary(r)
+	if len(r.Output) <= limit {
+		if r.Output != "" {
+			body += "\nOutput:\n" + r.Output
+		}
+		msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&msg, body); err != nil {
+			return nil, err
+		}
+		return msg.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	body += "\nThe output is attached since it exceeds the limit.\n"
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(pw, body); err != nil {
+		return nil, err
+	}
+	pw, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+		"Content-Disposition":       {`attachment; filename="output.txt"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(pw, r.Output); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+func (ho *horenso) sendMail(h handler, msg []byte) error {
+	u, err := url.Parse(strings.TrimPrefix(h.Command, mailScheme))
+	if err != nil {
+		return maskURLError(err, strings.TrimPrefix(h.name(), mailScheme))
+	}
+	if u.Scheme != "smtp" {
+		return fmt.Errorf("unsupported scheme: %q", u.Scheme)
+	}
+	host := u.Hostname()
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(host, "25")
+	}
+	if h.From == "" || len(h.To) == 0 {
+		return fmt.Errorf("from and to are required")
+	}
+	timeout := ho.handlerTimeout(h)
+	if timeout <= 0 {
+		timeout = defaultMailTimeout
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if h.StartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if h.AuthFile != "" {
+		user, pass, err := mailAuth(h.AuthFile)
+		if err != nil {
+			return err
+		}
+		if err := c.Auth(smtp.PlainAuth("", user, pass, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(h.From); err != nil {
+		return err
+	}
+	for _, to := range h.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// runMail sends the report by mail via SMTP
+func (ho *horenso) runMail(h handler, report []byte) error {
+	ho.logf(info, "starting to send the report by %q", h.name())
+	var r Report
+	if err := json.Unmarshal(report, &r); err != nil {
+		ho.logf(warn, "failed to parse the report for %q: %s", h.name(), err)
+		return err
+	}
+	msg, err := buildMail(h, r, time.Now())
+	if err != nil {
+		ho.logf(warn, "failed to build the mail for %q: %s", h.name(), err)
+		return err
+	}
+	if err := ho.sendMail(h, msg); err != nil {
+		ho.logf(warn, "failed to send the report by %q: %s", h.name(), err)
+		return err
+	}
+	ho.logf(info, "finished to send the report by %q", h.name())
+	return nil
+}
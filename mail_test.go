@@ -0,0 +1,124 @@
+package horenso
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts a mail and sends the data to the returned channel
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan string, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				ch <- data.String()
+				reply("250 ok")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestRunMail(t *testing.T) {
+	f := temp()
+	defer os.RemoveAll(f)
+	ioutil.WriteFile(f, []byte(`reporter:
+- command: mail+smtp://127.0.0.1:25
+  from: horenso@example.com
+  to: [ops@example.com]
+  subject: "job {{.Tag}} {{.Status}}"
+  attachLimit: 10
+`), 0644)
+	c, err := loadConfig(f)
+	if err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+	h := c.Reporter[0]
+	if h.From != "horenso@example.com" || len(h.To) != 1 || h.AttachLimit != 10 {
+		t.Fatalf("unexpected handler: %#v", h)
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		attach bool
+	}{
+		{name: "inline", output: "ok\n", attach: false},
+		{name: "attachment", output: strings.Repeat("x", 20), attach: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, ch := fakeSMTPServer(t)
+			h.Command = "mail+smtp://" + addr
+			report, _ := json.Marshal(Report{
+				Command:  "backup.sh",
+				Tag:      "backup",
+				Output:   tt.output,
+				Hostname: "db1",
+				Result:   "command exited with code: 0",
+				Status:   "success",
+			})
+			ho := &horenso{}
+			if err := ho.runHandler(h, report, phaseReport); err != nil {
+				t.Fatalf("err should be nil but: %s", err)
+			}
+			data := <-ch
+			if !strings.Contains(data, "Subject: job backup success\r\n") {
+				t.Errorf("unexpected subject:\n%s", data)
+			}
+			if !strings.Contains(data, "To: ops@example.com\r\n") {
+				t.Errorf("unexpected to:\n%s", data)
+			}
+			if got := strings.Contains(data, `filename="output.txt"`); got != tt.attach {
+				t.Errorf("attached should be %t but:\n%s", tt.attach, data)
+			}
+			if !strings.Contains(data, strings.TrimSpace(tt.output)) {
+				t.Errorf("the output should be contained:\n%s", data)
+			}
+		})
+	}
+}
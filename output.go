@@ -0,0 +1,209 @@
+package horenso
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// outputLimit is the limit of each captured output. It is specified as "N" to retain the
+// first and last halves of N bytes, or "N:M" to retain the first N and last M bytes.
+type outputLimit struct {
+	head, tail int
+}
+
+func parseOutputLimit(s string) (outputLimit, error) {
+	var l outputLimit
+	var err error
+	if h, t, ok := strings.Cut(s, ":"); ok {
+		l.head, err = strconv.Atoi(strings.TrimSpace(h))
+		if err == nil {
+			l.tail, err = strconv.Atoi(strings.TrimSpace(t))
+		}
+	} else {
+		var n int
+		n, err = strconv.Atoi(strings.TrimSpace(s))
+		l.head, l.tail = n/2, n-n/2
+	}
+	if err != nil || l.head < 0 || l.tail < 0 {
+		return outputLimit{}, fmt.Errorf("invalid max output %q: it should be bytes or head:tail bytes which are not negative", s)
+	}
+	return l, nil
+}
+
+func (l outputLimit) isZero() bool {
+	return l.head == 0 && l.tail == 0
+}
+
+func (l outputLimit) String() string {
+	if n := l.head + l.tail; l.head == n/2 {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%d:%d", l.head, l.tail)
+}
+
+// UnmarshalFlag implements flags.Unmarshaler
+func (l *outputLimit) UnmarshalFlag(s string) (err error) {
+	*l, err = parseOutputLimit(s)
+	return err
+}
+
+// UnmarshalYAML accepts bytes or "head:tail"
+func (l *outputLimit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return l.UnmarshalFlag(s)
+}
+
+// MarshalYAML marshals the limit as bytes if possible
+func (l outputLimit) MarshalYAML() (interface{}, error) {
+	s := l.String()
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	return s, nil
+}
+
+// outputBuffer is a buffer for capturing the output of the command. When the
+// limit is set, it retains only the first and the last bytes of the limit and
+// drops the rest. When discard is set, it only counts the bytes.
+type outputBuffer struct {
+	limit   outputLimit
+	discard bool
+	head    []byte
+	tail    []byte
+	written int64
+	mu      sync.Mutex
+}
+
+func newOutputBuffer(limit outputLimit) *outputBuffer {
+	return &outputBuffer{limit: limit}
+}
+
+func newDiscardBuffer() *outputBuffer {
+	return &outputBuffer{discard: true}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	b.written += int64(n)
+	if b.discard {
+		return n, nil
+	}
+	if b.limit.isZero() {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+	if rest := b.limit.head - len(b.head); rest > 0 {
+		if rest > len(p) {
+			rest = len(p)
+		}
+		b.head = append(b.head, p[:rest]...)
+		p = p[rest:]
+	}
+	b.tail = append(b.tail, p...)
+	// compact lazily to avoid copying the tail on every write
+	if size := b.limit.tail; len(b.tail) > 2*size {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-size:]...)
+	}
+	return n, nil
+}
+
+func (b *outputBuffer) retainedTail() []byte {
+	if size := b.limit.tail; len(b.tail) > size {
+		return b.tail[len(b.tail)-size:]
+	}
+	return b.tail
+}
+
+// Len returns the number of bytes written to the buffer including dropped ones
+func (b *outputBuffer) Len() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written
+}
+
+// Truncated reports whether some of the output were dropped
+func (b *outputBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated()
+}
+
+func (b *outputBuffer) truncated() bool {
+	if b.discard {
+		return false
+	}
+	return b.written > int64(len(b.head)+len(b.retainedTail()))
+}
+
+func (b *outputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.discard {
+		return ""
+	}
+	tail := b.retainedTail()
+	if !b.truncated() {
+		return string(b.head) + string(tail)
+	}
+	dropped := b.written - int64(len(b.head)+len(tail))
+	return string(b.head) + fmt.Sprintf("\n... (%d bytes truncated) ...\n", dropped) + string(tail)
+}
+
+// outputFiles are files in a private temporary directory to which the outputs
+// of the command are streamed
+type outputFiles struct {
+	dir                    string
+	output, stdout, stderr *os.File
+}
+
+func createOutputFiles() (*outputFiles, error) {
+	dir, err := ioutil.TempDir("", "horenso")
+	if err != nil {
+		return nil, err
+	}
+	of := &outputFiles{dir: dir}
+	for _, v := range []struct {
+		f    **os.File
+		name string
+	}{
+		{&of.output, "output.log"},
+		{&of.stdout, "stdout.log"},
+		{&of.stderr, "stderr.log"},
+	} {
+		f, err := os.OpenFile(filepath.Join(dir, v.name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			of.Close()
+			of.remove()
+			return nil, err
+		}
+		*v.f = f
+	}
+	return of, nil
+}
+
+func (of *outputFiles) Close() error {
+	var err error
+	for _, f := range []*os.File{of.output, of.stdout, of.stderr} {
+		if f == nil {
+			continue
+		}
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (of *outputFiles) remove() error {
+	return os.RemoveAll(of.dir)
+}
@@ -0,0 +1,24 @@
+package horenso
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func forwardSignal(p *os.Process, sig os.Signal) error {
+	return p.Kill()
+}
+
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+func kill(p *os.Process) error {
+	return p.Kill()
+}
+
+func descendantsAlive(p *os.Process) bool {
+	return false
+}
@@ -0,0 +1,137 @@
+package horenso
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+)
+
+type replayOpts struct {
+	FromHistory string   `long:"from-history" value-name:"tag" description:"replay the last report of the tag in the history directory"`
+	Set         []string `long:"set" value-name:"field=value" description:"override the field of the report like 'exitCode=1'. the value is parsed as JSON if possible"`
+}
+
+// readReport reads the report from the file. "-" means STDIN.
+func readReport(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// lastReport reads the last report of the tag from the history
+func (ho *horenso) lastReport(tag string) ([]byte, error) {
+	if ho.HistoryDir == "" {
+		return nil, fmt.Errorf("history directory is not specified")
+	}
+	reports, err := ho.loadHistory(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no history found for the tag %q", tag)
+	}
+	return json.Marshal(reports[len(reports)-1])
+}
+
+// overrideReport overrides the fields of the report in JSON
+func overrideReport(b []byte, overrides []string) (Report, error) {
+	var r Report
+	if len(overrides) > 0 {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return r, err
+		}
+		for _, o := range overrides {
+			kv := strings.SplitN(o, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return r, fmt.Errorf("invalid override %q. it should be field=value", o)
+			}
+			var v interface{}
+			if err := json.Unmarshal([]byte(kv[1]), &v); err != nil {
+				v = kv[1]
+			}
+			fields[kv[0]] = v
+		}
+		var err error
+		if b, err = json.Marshal(fields); err != nil {
+			return r, err
+		}
+	}
+	err := json.Unmarshal(b, &r)
+	return r, err
+}
+
+// noticeReport returns the report which the noticers receive at the start of the job
+func noticeReport(r Report) Report {
+	return Report{
+		Command:     r.Command,
+		CommandArgs: r.CommandArgs,
+		Tag:         r.Tag,
+		ExitCode:    -1,
+		Hostname:    r.Hostname,
+		Pid:         r.Pid,
+		StartAt:     r.StartAt,
+		OutputFile:  r.OutputFile,
+		StdoutFile:  r.StdoutFile,
+		StderrFile:  r.StderrFile,
+	}
+}
+
+// replay runs the noticers and the reporters with the saved report
+func (ho *horenso) replay(r Report) error {
+	ho.resolveStatus(&r)
+	done := make(chan error)
+	go func() {
+		done <- ho.runNoticer(noticeReport(r))
+	}()
+	err := ho.runReporter(r)
+	if nerr := <-done; err == nil {
+		err = nerr
+	}
+	return err
+}
+
+func runReplay(args []string) int {
+	ho := &horenso{outStream: os.Stdout, errStream: os.Stderr}
+	opts := &replayOpts{}
+	p := flags.NewParser(ho, flags.Default)
+	p.Usage = "replay [OPTIONS] [--set field=value...] <report.json | - | --from-history tag>"
+	p.AddGroup("Replay Options", "", opts)
+	rest, err := p.ParseArgs(args)
+	validArgs := (opts.FromHistory != "" && len(rest) == 0) || (opts.FromHistory == "" && len(rest) == 1)
+	if err != nil || !validArgs {
+		if ferr, ok := err.(*flags.Error); !ok || ferr.Type != flags.ErrHelp {
+			p.WriteHelp(ho.errStream)
+		}
+		return 2
+	}
+	ho.setupLog()
+	if err := ho.loadConfig(); err != nil {
+		ho.logf(warn, "failed to load config: %s", err)
+	}
+
+	var b []byte
+	if opts.FromHistory != "" {
+		b, err = ho.lastReport(opts.FromHistory)
+	} else {
+		b, err = readReport(rest[0])
+	}
+	if err != nil {
+		fmt.Fprintf(ho.errStream, "failed to load the report: %s\n", err)
+		return 1
+	}
+	r, err := overrideReport(b, opts.Set)
+	if err != nil {
+		fmt.Fprintf(ho.errStream, "failed to load the report: %s\n", err)
+		return 1
+	}
+	if err := ho.replay(r); err != nil {
+		return 1
+	}
+	return 0
+}
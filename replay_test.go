@@ -0,0 +1,50 @@
+package horenso
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	fname := temp()
+	noticeReport := temp()
+	defer func() {
+		for _, f := range []string{fname, noticeReport} {
+			os.RemoveAll(f)
+		}
+	}()
+	ho := &horenso{
+		Reporter: []string{"go run testdata/reporter.go " + fname},
+		Noticer:  []string{"go run testdata/reporter.go " + noticeReport},
+	}
+	ho.loadConfig()
+
+	src := []byte(`{"command":"backup.sh","tag":"backup","output":"ok\n","exitCode":0,"result":"command exited with code: 0","hostname":"db1","status":"success"}`)
+	r, err := overrideReport(src, []string{"exitCode=2", "result=command exited with code: 2"})
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if err := ho.replay(r); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+
+	rr := parseReport(fname)
+	if rr.ExitCode != 2 || rr.Result != "command exited with code: 2" {
+		t.Errorf("the fields should be overridden but: %#v", rr)
+	}
+	if rr.Status != "failure" || rr.Severity != "critical" {
+		t.Errorf("status should be resolved again but: %s/%s", rr.Status, rr.Severity)
+	}
+	if rr.Output != "ok\n" || rr.Tag != "backup" {
+		t.Errorf("the other fields should be kept but: %#v", rr)
+	}
+
+	nr := parseReport(noticeReport)
+	if nr.ExitCode != -1 || nr.Output != "" || nr.Command != "backup.sh" {
+		t.Errorf("the noticer should receive the report of the start but: %#v", nr)
+	}
+
+	if _, err := overrideReport(src, []string{"exitCode"}); err == nil {
+		t.Errorf("err shouldn't be nil")
+	}
+}
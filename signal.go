@@ -0,0 +1,109 @@
+package horenso
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var forwardedSignals = []os.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM}
+
+// signalTrap traps the signals horenso receives from before acquiring the lock until the
+// reporters start, so that the job interrupted from outside is still reported. The signals
+// are forwarded to the command once it is started.
+type signalTrap struct {
+	ho       *horenso
+	command  string
+	sigCh    chan os.Signal
+	notified chan struct{}
+	quit     chan struct{}
+	stopOnce sync.Once
+
+	mu    sync.Mutex
+	first os.Signal
+	proc  *os.Process
+}
+
+func (ho *horenso) trapSignals(command string) *signalTrap {
+	t := &signalTrap{
+		ho:       ho,
+		command:  command,
+		sigCh:    make(chan os.Signal, 1),
+		notified: make(chan struct{}),
+		quit:     make(chan struct{}),
+	}
+	signal.Notify(t.sigCh, forwardedSignals...)
+	go t.loop()
+	return t
+}
+
+func (t *signalTrap) loop() {
+	for {
+		select {
+		case <-t.quit:
+			return
+		case sig := <-t.sigCh:
+			t.mu.Lock()
+			if t.first == nil {
+				t.first = sig
+				close(t.notified)
+			}
+			p := t.proc
+			t.mu.Unlock()
+			if p == nil {
+				t.ho.logf(warn, "received signal %q before starting the command %q", sig, t.command)
+				continue
+			}
+			t.forward(p, sig)
+		}
+	}
+}
+
+func (t *signalTrap) forward(p *os.Process, sig os.Signal) {
+	t.ho.logf(warn, "received signal %q. forwarding it to the command %q", sig, t.command)
+	if err := forwardSignal(p, sig); err != nil {
+		t.ho.logf(warn, "failed to forward signal %q to the command %q: %s", sig, t.command, err)
+	}
+}
+
+// setProcess starts forwarding the signals to the started command. The signal received
+// before is forwarded immediately.
+func (t *signalTrap) setProcess(p *os.Process) {
+	t.mu.Lock()
+	t.proc = p
+	sig := t.first
+	t.mu.Unlock()
+	if sig != nil {
+		t.forward(p, sig)
+	}
+}
+
+// interrupted returns the channel which is closed when the first signal is received
+func (t *signalTrap) interrupted() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.notified
+}
+
+// received returns the first received signal or nil
+func (t *signalTrap) received() os.Signal {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.first
+}
+
+// stop stops trapping the signals, so that they terminate horenso as usual
+func (t *signalTrap) stop() {
+	if t == nil {
+		return
+	}
+	t.stopOnce.Do(func() {
+		signal.Stop(t.sigCh)
+		close(t.quit)
+	})
+}
@@ -0,0 +1,112 @@
+package horenso
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const slackScheme = "slack+"
+
+const (
+	defaultSlackTitle = `{{if .Tag}}{{.Tag}}{{else}}{{.Command}}{{end}}`
+	defaultSlackText  = "{{.Result}}{{if .Output}}\n```\n{{truncate 2000 .Output}}\n```{{end}}"
+)
+
+func isSlack(h handler) bool {
+	return strings.HasPrefix(h.Command, slackScheme)
+}
+
+type slackPayload struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text"`
+	Fields   []slackField `json:"fields"`
+	MrkdwnIn []string     `json:"mrkdwn_in"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func slackColor(r Report) string {
+	switch r.Severity {
+	case severityOK:
+		return "good"
+	case severityWarning:
+		return "warning"
+	case severityCritical:
+		return "danger"
+	}
+	return "#808080"
+}
+
+func buildSlackPayload(h handler, r Report) ([]byte, error) {
+	titleTmpl := h.Title
+	if titleTmpl == "" {
+		titleTmpl = defaultSlackTitle
+	}
+	title, err := renderTemplate("title", titleTmpl, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the title: %s", err)
+	}
+	textTmpl := h.Text
+	if textTmpl == "" {
+		textTmpl = defaultSlackText
+	}
+	text, err := renderTemplate("text", textTmpl, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the text: %s", err)
+	}
+	fields := []slackField{
+		{Title: "Host", Value: r.Hostname, Short: true},
+		{Title: "Exit Code", Value: fmt.Sprint(r.ExitCode), Short: true},
+	}
+	if d := reportDuration(r); d > 0 {
+		fields = append(fields, slackField{Title: "Duration", Value: d.String(), Short: true})
+	}
+	if r.Status != "" {
+		fields = append(fields, slackField{Title: "Status", Value: r.Status, Short: true})
+	}
+	return json.Marshal(slackPayload{
+		Channel:   h.Channel,
+		Username:  h.Username,
+		IconEmoji: h.IconEmoji,
+		Attachments: []slackAttachment{{
+			Fallback: fmt.Sprintf("%s: %s", title, r.Result),
+			Color:    slackColor(r),
+			Title:    title,
+			Text:     text,
+			Fields:   fields,
+			MrkdwnIn: []string{"text"},
+		}},
+	})
+}
+
+// runSlack posts the report to the Slack or Mattermost compatible incoming
+// webhook
+func (ho *horenso) runSlack(h handler, report []byte) error {
+	var r Report
+	if err := json.Unmarshal(report, &r); err != nil {
+		ho.logf(warn, "failed to parse the report for %q: %s", h.name(), err)
+		return err
+	}
+	payload, err := buildSlackPayload(h, r)
+	if err != nil {
+		ho.logf(warn, "failed to build the payload for %q: %s", h.name(), err)
+		return err
+	}
+	wh := h
+	wh.Command = webhookScheme + strings.TrimPrefix(h.Command, slackScheme)
+	return ho.runWebhook(wh, payload)
+}
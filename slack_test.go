@@ -0,0 +1,86 @@
+package horenso
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunSlack(t *testing.T) {
+	var got slackPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+	}))
+	defer ts.Close()
+
+	start := time.Date(2022, 8, 12, 4, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Second)
+	report, _ := json.Marshal(Report{
+		Command:  "backup.sh",
+		Tag:      "backup",
+		Output:   "disk full\n",
+		ExitCode: 2,
+		Result:   "command exited with code: 2",
+		Hostname: "db1",
+		StartAt:  &start,
+		EndAt:    &end,
+		Status:   "failure",
+		Severity: "critical",
+	})
+
+	tests := []struct {
+		name  string
+		h     handler
+		title string
+		text  string
+	}{
+		{
+			name:  "default",
+			h:     handler{Command: "slack+" + ts.URL},
+			title: "backup",
+			text:  "command exited with code: 2\n```\ndisk full\n\n```",
+		},
+		{
+			name: "template",
+			h: handler{
+				Command: "slack+" + ts.URL,
+				Title:   "[{{.Severity}}] {{.Command}}",
+				Text:    "took {{duration .}}",
+			},
+			title: "[critical] backup.sh",
+			text:  "took 1m30s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ho := &horenso{}
+			if err := ho.runHandler(tt.h, report, phaseReport); err != nil {
+				t.Errorf("err should be nil but: %s", err)
+			}
+			if len(got.Attachments) != 1 {
+				t.Fatalf("payload should have an attachment but: %#v", got)
+			}
+			a := got.Attachments[0]
+			if a.Title != tt.title {
+				t.Errorf("title should be %q but: %q", tt.title, a.Title)
+			}
+			if a.Text != tt.text {
+				t.Errorf("text should be %q but: %q", tt.text, a.Text)
+			}
+			if a.Color != "danger" {
+				t.Errorf("color should be danger but: %s", a.Color)
+			}
+			fields := map[string]string{}
+			for _, f := range a.Fields {
+				fields[f.Title] = f.Value
+			}
+			if fields["Host"] != "db1" || fields["Exit Code"] != "2" || fields["Duration"] != "1m30s" {
+				t.Errorf("unexpected fields: %#v", a.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package horenso
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// spoolEntry is a report which could not be delivered to the handler
+type spoolEntry struct {
+	Handler   handler         `json:"handler"`
+	Report    json.RawMessage `json:"report"`
+	Phase     string          `json:"phase,omitempty"`
+	SpooledAt time.Time       `json:"spooledAt"`
+}
+
+func (ho *horenso) spool(h handler, report []byte, phase string) error {
+	if err := os.MkdirAll(ho.SpoolDir, 0700); err != nil {
+		return err
+	}
+	// write to a temporary file and rename it not to flush a partially written entry
+	f, err := ioutil.TempFile(ho.SpoolDir, ".spool-")
+	if err != nil {
+		return err
+	}
+	entry := filepath.Join(ho.SpoolDir, fmt.Sprintf("%d-%s.json", time.Now().UnixNano(), strings.TrimPrefix(filepath.Base(f.Name()), ".spool-")))
+	fail := func(err error) error {
+		f.Close()
+		os.Remove(f.Name())
+		os.RemoveAll(spoolFilesDir(entry))
+		return err
+	}
+	report, err = spoolOutputFiles(report, spoolFilesDir(entry))
+	if err != nil {
+		return fail(err)
+	}
+	b, err := json.Marshal(spoolEntry{
+		Handler:   h,
+		Report:    report,
+		Phase:     phase,
+		SpooledAt: time.Now(),
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := f.Write(b); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(f.Name(), entry); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// spoolFilesDir returns the directory of the output files of the spool entry
+func spoolFilesDir(entry string) string {
+	return strings.TrimSuffix(entry, ".json") + ".files"
+}
+
+// spoolOutputFiles copies the output files referred by the report into dir, because they
+// are removed when horenso exits. It returns the report referring to the copies.
+func spoolOutputFiles(report []byte, dir string) ([]byte, error) {
+	var r map[string]json.RawMessage
+	if err := json.Unmarshal(report, &r); err != nil {
+		return nil, err
+	}
+	copied := false
+	for _, key := range []string{"outputFile", "stdoutFile", "stderrFile"} {
+		var file string
+		if err := json.Unmarshal(r[key], &file); err != nil || file == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		dst := filepath.Join(dir, filepath.Base(file))
+		if err := copyFile(file, dst); err != nil {
+			return nil, err
+		}
+		r[key], _ = json.Marshal(dst)
+		copied = true
+	}
+	if !copied {
+		return report, nil
+	}
+	return json.Marshal(r)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// flushSpool re-delivers the spooled reports to the handlers. Delivered entries
+// are removed from the spool directory.
+func (ho *horenso) flushSpool() (delivered, failed int, err error) {
+	files, err := filepath.Glob(filepath.Join(ho.SpoolDir, "*.json"))
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			ho.logf(warn, "failed to read the spooled report %q: %s", file, err)
+			failed++
+			continue
+		}
+		var ent spoolEntry
+		if err := json.Unmarshal(b, &ent); err != nil {
+			ho.logf(warn, "failed to parse the spooled report %q: %s", file, err)
+			failed++
+			continue
+		}
+		if err := ho.runHandler(ent.Handler, ent.Report, ent.Phase); err != nil {
+			failed++
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			ho.logf(warn, "failed to remove the spooled report %q: %s", file, err)
+		}
+		if err := os.RemoveAll(spoolFilesDir(file)); err != nil {
+			ho.logf(warn, "failed to remove the output files of the spooled report %q: %s", file, err)
+		}
+		delivered++
+	}
+	return delivered, failed, nil
+}
+
+type spoolOpts struct {
+	SpoolDir string `long:"spool-dir" value-name:"/path/to/spool" description:"spool directory"`
+	Config   string `short:"c" long:"config" value-name:"/path/to/config.yaml" description:"config file"`
+	Verbose  []bool `short:"v" long:"verbose" description:"verbose output. it can be stacked like -vv for more detailed log"`
+}
+
+func runSpool(args []string) int {
+	opts := &spoolOpts{}
+	p := flags.NewParser(opts, flags.Default)
+	p.Usage = "spool flush [--spool-dir=/path/to/spool] [--config=/path/to/config.yaml]"
+	if len(args) < 1 || args[0] != "flush" {
+		p.WriteHelp(os.Stderr)
+		return 2
+	}
+	rest, err := p.ParseArgs(args[1:])
+	if err != nil || len(rest) > 0 {
+		if ferr, ok := err.(*flags.Error); !ok || ferr.Type != flags.ErrHelp {
+			p.WriteHelp(os.Stderr)
+		}
+		return 2
+	}
+	ho := &horenso{
+		SpoolDir:  opts.SpoolDir,
+		Config:    opts.Config,
+		Verbose:   opts.Verbose,
+		outStream: os.Stdout,
+		errStream: os.Stderr,
+	}
+	ho.setupLog()
+	if err := ho.loadConfig(); err != nil {
+		ho.logf(warn, "failed to load config: %s", err)
+	}
+	if ho.SpoolDir == "" {
+		fmt.Fprintln(ho.errStream, "spool directory is not specified")
+		return 2
+	}
+	delivered, failed, err := ho.flushSpool()
+	if err != nil {
+		fmt.Fprintf(ho.errStream, "failed to flush the spool: %s\n", err)
+		return 1
+	}
+	fmt.Fprintf(ho.outStream, "%d reports delivered, %d failed\n", delivered, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package horenso
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_spool(t *testing.T) {
+	spoolDir, err := ioutil.TempDir("", "horenso-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter", "testdata/notfound",
+		"--handler-retry", "1",
+		"--spool-dir", spoolDir,
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	if _, err := ho.run(cmdArgs); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(spoolDir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("a report should be spooled but: %v", files)
+	}
+
+	delivered, failed, err := ho.flushSpool()
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if delivered != 0 || failed != 1 {
+		t.Errorf("delivered/failed should be 0/1 but: %d/%d", delivered, failed)
+	}
+}
+
+func TestFlushSpool(t *testing.T) {
+	spoolDir, err := ioutil.TempDir("", "horenso-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+	fname := temp()
+	defer os.RemoveAll(fname)
+
+	ho := &horenso{SpoolDir: spoolDir}
+	report := []byte(`{"command":"echo","exitCode":1}`)
+	if err := ho.spool(handler{Command: "go run testdata/reporter.go " + fname}, report, phaseReport); err != nil {
+		t.Fatalf("failed to spool: %s", err)
+	}
+
+	delivered, failed, err := ho.flushSpool()
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if delivered != 1 || failed != 0 {
+		t.Errorf("delivered/failed should be 1/0 but: %d/%d", delivered, failed)
+	}
+	b, _ := ioutil.ReadFile(fname)
+	if string(b) != string(report) {
+		t.Errorf("the report should be %s but: %s", report, b)
+	}
+	files, _ := filepath.Glob(filepath.Join(spoolDir, "*"))
+	if len(files) != 0 {
+		t.Errorf("the spool should be empty but: %v", files)
+	}
+}
+
+func TestRun_spoolOutputFiles(t *testing.T) {
+	spoolDir, err := ioutil.TempDir("", "horenso-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+	_, ho, cmdArgs, err := parseArgs([]string{
+		"--reporter", "testdata/notfound",
+		"--spill-output",
+		"--spool-dir", spoolDir,
+		"--",
+		"go", "run", "testdata/run.go",
+	})
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	ho.errStream = ioutil.Discard
+	ho.outStream = ioutil.Discard
+
+	r, err := ho.run(cmdArgs)
+	if err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if _, err := os.Stat(r.OutputFile); !os.IsNotExist(err) {
+		t.Errorf("the output files should be removed after running")
+	}
+	files, _ := filepath.Glob(filepath.Join(spoolDir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("a report should be spooled but: %v", files)
+	}
+	b, _ := ioutil.ReadFile(files[0])
+	var ent struct {
+		Report Report `json:"report"`
+	}
+	if err := json.Unmarshal(b, &ent); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadFile(ent.Report.OutputFile)
+	if err != nil || string(out) != "1\n2\n3\n" {
+		t.Errorf("the output files should be copied into the spool but: %q, %v", out, err)
+	}
+
+	fname := temp()
+	defer os.RemoveAll(fname)
+	b, _ = ioutil.ReadFile(files[0])
+	b = []byte(strings.Replace(string(b), `"testdata/notfound"`, `"go run testdata/reporter.go `+fname+`"`, 1))
+	ioutil.WriteFile(files[0], b, 0600)
+	if delivered, _, _ := ho.flushSpool(); delivered != 1 {
+		t.Fatalf("the report should be delivered")
+	}
+	if _, err := os.Stat(spoolFilesDir(files[0])); !os.IsNotExist(err) {
+		t.Errorf("the output files should be removed with the delivered entry")
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		i        int
+		expect   time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 3, 8 * time.Second},
+		{time.Second, 10, maxRetryWait},
+		{time.Second, 100, maxRetryWait},
+		{2 * time.Minute, 5, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := retryWait(tt.interval, tt.i); got != tt.expect {
+			t.Errorf("retryWait(%s, %d) should be %s but: %s", tt.interval, tt.i, tt.expect, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package horenso
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// jobState is the outcome of the previous run of the job
+type jobState struct {
+	ExitCode            int        `json:"exitCode"`
+	Status              string     `json:"status"`
+	EndAt               *time.Time `json:"endAt,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// jobKey returns the key identifying the job, which is the tag or the hash of the command
+func jobKey(r Report) string {
+	if r.Tag != "" {
+		return "tag-" + unsafeFileChars.ReplaceAllString(r.Tag, "_")
+	}
+	sum := sha256.Sum256([]byte(r.Command))
+	return "cmd-" + hex.EncodeToString(sum[:8])
+}
+
+func (ho *horenso) stateFile(r Report) string {
+	return filepath.Join(ho.StateDir, jobKey(r)+".json")
+}
+
+func (ho *horenso) loadState(r Report) (*jobState, error) {
+	b, err := ioutil.ReadFile(ho.stateFile(r))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	st := &jobState{}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (ho *horenso) saveState(r Report, st *jobState) error {
+	if err := os.MkdirAll(ho.StateDir, 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(ho.StateDir, ".state-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), ho.stateFile(r))
+}
+
+// updateState fills the outcome of the previous run into the report and
+// stores the outcome of this run. Skipped runs are not stored.
+func (ho *horenso) updateState(r *Report) {
+	if ho.StateDir == "" {
+		return
+	}
+	prev, err := ho.loadState(*r)
+	if err != nil {
+		ho.logf(warn, "failed to load the state of the job: %s", err)
+	}
+	if prev != nil {
+		code := prev.ExitCode
+		r.PreviousExitCode = &code
+		r.PreviousEndAt = prev.EndAt
+		r.PreviousStatus = prev.Status
+	}
+	if r.Status == statusSkipped {
+		if prev != nil {
+			r.ConsecutiveFailures = prev.ConsecutiveFailures
+		}
+		return
+	}
+	if r.Status == statusFailure {
+		r.ConsecutiveFailures = 1
+		if prev != nil {
+			r.ConsecutiveFailures = prev.ConsecutiveFailures + 1
+		}
+	}
+	st := &jobState{
+		ExitCode:            r.ExitCode,
+		Status:              r.Status,
+		EndAt:               r.EndAt,
+		ConsecutiveFailures: r.ConsecutiveFailures,
+	}
+	if err := ho.saveState(*r, st); err != nil {
+		ho.logf(warn, "failed to save the state of the job: %s", err)
+	}
+}
+
+// stateChanged reports whether the job transitioned from success to failure
+// or from failure to success. The first failure is also treated as a change.
+func stateChanged(r Report) bool {
+	if r.Status != statusSuccess && r.Status != statusFailure {
+		return false
+	}
+	if r.PreviousStatus == "" {
+		return r.Status == statusFailure
+	}
+	return (r.PreviousStatus == statusSuccess) != (r.Status == statusSuccess)
+}
@@ -0,0 +1,59 @@
+package horenso
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestUpdateState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "horenso-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ho := &horenso{StateDir: dir}
+
+	tests := []struct {
+		exitCode            int
+		changed             bool
+		hasPrevious         bool
+		previousExitCode    int
+		consecutiveFailures int
+	}{
+		{exitCode: 1, changed: true, hasPrevious: false, consecutiveFailures: 1},
+		{exitCode: 2, changed: false, hasPrevious: true, previousExitCode: 1, consecutiveFailures: 2},
+		{exitCode: 0, changed: true, hasPrevious: true, previousExitCode: 2, consecutiveFailures: 0},
+		{exitCode: 0, changed: false, hasPrevious: true, previousExitCode: 0, consecutiveFailures: 0},
+		{exitCode: 1, changed: true, hasPrevious: true, previousExitCode: 0, consecutiveFailures: 1},
+	}
+	for i, tt := range tests {
+		r := Report{Command: "backup.sh", Tag: "backup", ExitCode: tt.exitCode, EndAt: now()}
+		ho.resolveStatus(&r)
+		ho.updateState(&r)
+		if got := stateChanged(r); got != tt.changed {
+			t.Errorf("%d: changed should be %t but: %t", i, tt.changed, got)
+		}
+		if !tt.hasPrevious {
+			if r.PreviousExitCode != nil || r.PreviousEndAt != nil {
+				t.Errorf("%d: previous outcome should be empty but: %#v", i, r)
+			}
+		} else if r.PreviousExitCode == nil || *r.PreviousExitCode != tt.previousExitCode {
+			t.Errorf("%d: PreviousExitCode should be %d but: %v", i, tt.previousExitCode, r.PreviousExitCode)
+		}
+		if r.ConsecutiveFailures != tt.consecutiveFailures {
+			t.Errorf("%d: ConsecutiveFailures should be %d but: %d", i, tt.consecutiveFailures, r.ConsecutiveFailures)
+		}
+	}
+
+	r := Report{Command: "backup.sh", Tag: "backup", LockStatus: "skipped"}
+	ho.resolveStatus(&r)
+	ho.updateState(&r)
+	if r.ConsecutiveFailures != 1 || r.PreviousStatus != "failure" {
+		t.Errorf("skipped run should keep the previous outcome but: %#v", r)
+	}
+	st, _ := ho.loadState(r)
+	if st == nil || st.ConsecutiveFailures != 1 {
+		t.Errorf("skipped run shouldn't be stored but: %#v", st)
+	}
+}
@@ -0,0 +1,61 @@
+package horenso
+
+import "fmt"
+
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+	statusSkipped = "skipped"
+)
+
+const (
+	severityOK       = "ok"
+	severityWarning  = "warning"
+	severityCritical = "critical"
+	severityUnknown  = "unknown"
+)
+
+func validateSeverities(severities map[int]string) error {
+	for code, sev := range severities {
+		switch sev {
+		case severityOK, severityWarning, severityCritical, severityUnknown:
+		default:
+			return fmt.Errorf("invalid severity %q for exit code %d", sev, code)
+		}
+	}
+	return nil
+}
+
+func (ho *horenso) isSuccessExitCode(code int) bool {
+	if len(ho.successExitCodes) == 0 {
+		return code == 0
+	}
+	for _, c := range ho.successExitCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveStatus fills the Status and the Severity of the finished job
+func (ho *horenso) resolveStatus(r *Report) {
+	switch {
+	case r.LockStatus == lockSkipped:
+		r.Status = statusSkipped
+		r.Severity = severityUnknown
+		return
+	case r.EndAt != nil && !r.Signaled && !r.TimedOut && ho.isSuccessExitCode(r.ExitCode):
+		r.Status = statusSuccess
+		r.Severity = severityOK
+	default:
+		r.Status = statusFailure
+		r.Severity = severityCritical
+	}
+	if r.EndAt == nil || r.Signaled || r.TimedOut {
+		return
+	}
+	if sev, ok := ho.severities[r.ExitCode]; ok {
+		r.Severity = sev
+	}
+}
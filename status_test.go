@@ -0,0 +1,78 @@
+package horenso
+
+import "testing"
+
+func TestResolveStatus(t *testing.T) {
+	c, err := loadConfig("testdata/config_status.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+	ho := &horenso{
+		successExitCodes: c.SuccessExitCodes,
+		severities:       c.Severity,
+	}
+	tests := []struct {
+		name     string
+		r        Report
+		status   string
+		severity string
+	}{
+		{
+			name:     "success",
+			r:        Report{ExitCode: 0, EndAt: now()},
+			status:   "success",
+			severity: "ok",
+		},
+		{
+			name:     "success with warning",
+			r:        Report{ExitCode: 1, EndAt: now()},
+			status:   "success",
+			severity: "warning",
+		},
+		{
+			name:     "mapped failure",
+			r:        Report{ExitCode: 3, EndAt: now()},
+			status:   "failure",
+			severity: "unknown",
+		},
+		{
+			name:     "unmapped failure",
+			r:        Report{ExitCode: 4, EndAt: now()},
+			status:   "failure",
+			severity: "critical",
+		},
+		{
+			name:     "signaled",
+			r:        Report{ExitCode: 129, Signaled: true, EndAt: now()},
+			status:   "failure",
+			severity: "critical",
+		},
+		{
+			name:     "timed out",
+			r:        Report{ExitCode: 1, TimedOut: true, EndAt: now()},
+			status:   "failure",
+			severity: "critical",
+		},
+		{
+			name:     "failed to execute",
+			r:        Report{ExitCode: -1},
+			status:   "failure",
+			severity: "critical",
+		},
+		{
+			name:     "skipped",
+			r:        Report{ExitCode: -1, LockStatus: "skipped"},
+			status:   "skipped",
+			severity: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.r
+			ho.resolveStatus(&r)
+			if r.Status != tt.status || r.Severity != tt.severity {
+				t.Errorf("status should be %s/%s but: %s/%s", tt.status, tt.severity, r.Status, r.Severity)
+			}
+		})
+	}
+}
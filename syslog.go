@@ -0,0 +1,205 @@
+package horenso
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	syslogCommand   = "syslog"
+	journaldCommand = "journald"
+
+	defaultSyslogSocket   = "/dev/log"
+	defaultJournaldSocket = "/run/systemd/journal/socket"
+	defaultSyslogTimeout  = 10 * time.Second
+
+	// sdID is the SD-ID of the structured data. 32473 is the private enterprise
+	// number reserved for documentation.
+	sdID = "horenso@32473"
+)
+
+// syslogOptions are options for the syslog reporter
+type syslogOptions struct {
+	Facility string `yaml:"facility,omitempty" json:"facility,omitempty"`
+}
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func isSyslog(h handler) bool {
+	return h.Command == syslogCommand || strings.HasPrefix(h.Command, syslogCommand+"+")
+}
+
+func isJournald(h handler) bool {
+	return h.Command == journaldCommand || strings.HasPrefix(h.Command, journaldCommand+"+")
+}
+
+// syslogSeverity returns the syslog severity derived from the exit status
+func syslogSeverity(r Report) int {
+	switch r.Severity {
+	case severityOK:
+		return 6 // info
+	case severityWarning:
+		return 4 // warning
+	case severityCritical:
+		return 3 // err
+	}
+	return 5 // notice
+}
+
+// socketAddr resolves the network and the address from the handler command
+// like "syslog+udp://127.0.0.1:514" or "journald+unix:///path/to/socket"
+func socketAddr(cmd, defaultSocket string) (network, addr string, err error) {
+	i := strings.Index(cmd, "+")
+	if i < 0 {
+		return "unixgram", defaultSocket, nil
+	}
+	u, err := url.Parse(cmd[i+1:])
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "unix", "unixgram":
+		return "unixgram", u.Path, nil
+	case "udp":
+		return "udp", u.Host, nil
+	}
+	return "", "", fmt.Errorf("unsupported network: %q", u.Scheme)
+}
+
+func sdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+func orNil(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogMsgID makes the tag a MSGID of RFC 5424, which is 1-32 printable US-ASCII characters.
+// The tag is kept as it is in the structured data.
+func syslogMsgID(tag string) string {
+	id := []byte(tag)
+	for i, c := range id {
+		if c < 33 || c > 126 {
+			id[i] = '_'
+		}
+	}
+	if len(id) > 32 {
+		id = id[:32]
+	}
+	return orNil(string(id))
+}
+
+// buildSyslogMessage formats the report as a RFC 5424 message
+func buildSyslogMessage(h handler, r Report, t time.Time) ([]byte, error) {
+	facility := syslogFacilities["user"]
+	if h.Facility != "" {
+		f, ok := syslogFacilities[h.Facility]
+		if !ok {
+			return nil, fmt.Errorf("unknown facility: %q", h.Facility)
+		}
+		facility = f
+	}
+	procID := "-"
+	if r.Pid > 0 {
+		procID = fmt.Sprint(r.Pid)
+	}
+	sd := fmt.Sprintf(`[%s tag="%s" exitCode="%d" duration="%s" host="%s" status="%s"]`,
+		sdID, sdEscape(r.Tag), r.ExitCode, sdEscape(reportDuration(r).String()),
+		sdEscape(r.Hostname), sdEscape(r.Status))
+	msg := fmt.Sprintf("<%d>1 %s %s horenso %s %s %s %s",
+		facility*8+syslogSeverity(r), t.Format(time.RFC3339Nano), orNil(r.Hostname),
+		procID, syslogMsgID(r.Tag), sd, r.Command+": "+r.Result)
+	return []byte(msg), nil
+}
+
+// buildJournaldMessage formats the report in the journald native protocol
+func buildJournaldMessage(r Report) []byte {
+	var b bytes.Buffer
+	add := func(k, v string) {
+		if !strings.Contains(v, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", k, v)
+			return
+		}
+		b.WriteString(k + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(v)))
+		b.WriteString(v + "\n")
+	}
+	add("MESSAGE", r.Command+": "+r.Result)
+	add("PRIORITY", fmt.Sprint(syslogSeverity(r)))
+	add("SYSLOG_IDENTIFIER", "horenso")
+	add("HORENSO_TAG", r.Tag)
+	add("HORENSO_COMMAND", r.Command)
+	add("HORENSO_EXIT_CODE", fmt.Sprint(r.ExitCode))
+	add("HORENSO_STATUS", r.Status)
+	add("HORENSO_DURATION", reportDuration(r).String())
+	add("HORENSO_HOST", r.Hostname)
+	if r.Pid > 0 {
+		add("HORENSO_PID", fmt.Sprint(r.Pid))
+	}
+	return b.Bytes()
+}
+
+func sendDatagram(network, addr string, msg []byte, timeout time.Duration) error {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	_, err = conn.Write(msg)
+	return err
+}
+
+// buildSyslogPayload resolves the destination and builds the message for the
+// syslog or journald handler
+func buildSyslogPayload(h handler, r Report) (network, addr string, msg []byte, err error) {
+	if isJournald(h) {
+		network, addr, err = socketAddr(h.Command, defaultJournaldSocket)
+		return network, addr, buildJournaldMessage(r), err
+	}
+	network, addr, err = socketAddr(h.Command, defaultSyslogSocket)
+	if err != nil {
+		return "", "", nil, err
+	}
+	msg, err = buildSyslogMessage(h, r, time.Now())
+	return network, addr, msg, err
+}
+
+// runSyslog writes the report to the local syslog or journald socket
+func (ho *horenso) runSyslog(h handler, report []byte) error {
+	ho.logf(info, "starting to write the report to %q", h.name())
+	var r Report
+	if err := json.Unmarshal(report, &r); err != nil {
+		ho.logf(warn, "failed to parse the report for %q: %s", h.name(), err)
+		return err
+	}
+	network, addr, msg, err := buildSyslogPayload(h, r)
+	if err != nil {
+		ho.logf(warn, "failed to build the message for %q: %s", h.name(), err)
+		return err
+	}
+	timeout := ho.handlerTimeout(h)
+	if timeout <= 0 {
+		timeout = defaultSyslogTimeout
+	}
+	if err := sendDatagram(network, addr, msg, timeout); err != nil {
+		ho.logf(warn, "failed to write the report to %q: %s", h.name(), err)
+		return err
+	}
+	ho.logf(info, "finished to write the report to %q", h.name())
+	return nil
+}
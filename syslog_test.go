@@ -0,0 +1,115 @@
+package horenso
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenUnixgram(t *testing.T) (string, net.PacketConn, func()) {
+	dir, err := ioutil.TempDir("", "horenso-syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "sock")
+	conn, err := net.ListenPacket("unixgram", sock)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return sock, conn, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRunSyslog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain socket is not supported on windows")
+	}
+	start := time.Date(2022, 8, 12, 4, 0, 0, 0, time.UTC)
+	end := start.Add(1500 * time.Millisecond)
+	report, _ := json.Marshal(Report{
+		Command:  "backup.sh",
+		Tag:      "backup",
+		Output:   "disk full\n",
+		ExitCode: 2,
+		Result:   "command exited with code: 2",
+		Hostname: "db1",
+		Pid:      1234,
+		StartAt:  &start,
+		EndAt:    &end,
+		Status:   "failure",
+		Severity: "critical",
+	})
+
+	t.Run("syslog", func(t *testing.T) {
+		sock, conn, cleanup := listenUnixgram(t)
+		defer cleanup()
+		ho := &horenso{}
+		h := handler{Command: "syslog+unix://" + sock}
+		h.Facility = "local0"
+		if err := ho.runHandler(h, report, phaseReport); err != nil {
+			t.Fatalf("err should be nil but: %s", err)
+		}
+		buf := make([]byte, 4096)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reg := regexp.MustCompile(`\A<131>1 \S+ db1 horenso 1234 backup ` +
+			regexp.QuoteMeta(`[horenso@32473 tag="backup" exitCode="2" duration="1.5s" host="db1" status="failure"] `+
+				`backup.sh: command exited with code: 2`) + `\z`)
+		if !reg.Match(buf[:n]) {
+			t.Errorf("unexpected message: %s", buf[:n])
+		}
+	})
+
+	t.Run("journald", func(t *testing.T) {
+		sock, conn, cleanup := listenUnixgram(t)
+		defer cleanup()
+		ho := &horenso{}
+		if err := ho.runHandler(handler{Command: "journald+unix://" + sock}, report, phaseReport); err != nil {
+			t.Fatalf("err should be nil but: %s", err)
+		}
+		buf := make([]byte, 4096)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := string(buf[:n])
+		for _, expect := range []string{
+			"MESSAGE=backup.sh: command exited with code: 2\n",
+			"PRIORITY=3\n",
+			"HORENSO_TAG=backup\n",
+			"HORENSO_EXIT_CODE=2\n",
+		} {
+			if !strings.Contains(msg, expect) {
+				t.Errorf("message should contain %q but: %q", expect, msg)
+			}
+		}
+	})
+}
+
+func TestSyslogMsgID(t *testing.T) {
+	tests := []struct {
+		tag, expect string
+	}{
+		{"", "-"},
+		{"daily backup", "daily_backup"},
+		{"バックアップ", "__________________"},
+		{strings.Repeat("a", 40), strings.Repeat("a", 32)},
+	}
+	for _, tt := range tests {
+		if got := syslogMsgID(tt.tag); got != tt.expect {
+			t.Errorf("syslogMsgID(%q) should be %q but: %q", tt.tag, tt.expect, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package horenso
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const defaultReportFormat = `{{.Command}}: {{.Result}}
+{{- if .Tag}}
+  tag:      {{.Tag}}{{end}}
+  host:     {{.Hostname}}
+{{- if .Status}}
+  status:   {{.Status}} ({{.Severity}}){{end}}
+{{- if .EndAt}}
+  duration: {{duration .}}{{end}}
+  stdout:   {{humanBytes .StdoutBytes}}
+  stderr:   {{humanBytes .StderrBytes}}
+{{- if .Output}}
+  output:
+{{indent 4 .Output}}{{end}}
+`
+
+var templateFuncs = template.FuncMap{
+	"duration":   reportDuration,
+	"truncate":   truncate,
+	"indent":     indent,
+	"humanBytes": humanBytes,
+}
+
+func newTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+func renderTemplate(name, text string, r Report) (string, error) {
+	tmpl, err := newTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderReport renders the report in JSON with the template
+func renderReport(format string, report []byte) ([]byte, error) {
+	var r Report
+	if err := json.Unmarshal(report, &r); err != nil {
+		return nil, err
+	}
+	out, err := renderTemplate("report", format, r)
+	return []byte(out), err
+}
+
+// reportDuration returns the duration of the job. It returns zero when the
+// job hasn't finished.
+func reportDuration(r Report) time.Duration {
+	if r.StartAt == nil || r.EndAt == nil {
+		return 0
+	}
+	return r.EndAt.Sub(*r.StartAt).Round(time.Millisecond)
+}
+
+// truncate cuts the string down to n bytes with the trailing ellipsis. It doesn't split a
+// multibyte character.
+func truncate(n int, s string) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// indent adds n spaces to the head of each line
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.SplitAfter(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = pad + l
+	}
+	return strings.Join(lines, "")
+}
+
+// humanBytes formats the size in bytes with binary prefixes like "1.5 KiB"
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
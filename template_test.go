@@ -0,0 +1,77 @@
+package horenso
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	start := time.Date(2022, 8, 12, 4, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Minute)
+	r := Report{
+		Command:     "backup.sh",
+		Tag:         "バックアップ",
+		Output:      "line1\nline2\n",
+		StdoutBytes: 1536,
+		StartAt:     &start,
+		EndAt:       &end,
+	}
+	tests := []struct {
+		format string
+		expect string
+	}{
+		{`{{duration .}}`, "2m0s"},
+		{`{{truncate 3 .Command}}`, "bac..."},
+		{`{{truncate 20 .Command}}`, "backup.sh"},
+		{`{{truncate 4 .Tag}}`, "バ..."},
+		{`{{indent 2 .Output}}`, "  line1\n  line2"},
+		{`{{humanBytes .StdoutBytes}}`, "1.5 KiB"},
+		{`{{humanBytes .StderrBytes}}`, "0 B"},
+	}
+	for _, tt := range tests {
+		got, err := renderTemplate("test", tt.format, r)
+		if err != nil {
+			t.Errorf("%s: err should be nil but: %s", tt.format, err)
+		}
+		if got != tt.expect {
+			t.Errorf("%s: should be %q but: %q", tt.format, tt.expect, got)
+		}
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var out, errOut bytes.Buffer
+	ho := &horenso{
+		PrintReport: "stderr",
+		Format:      "{{.Command}} {{.ExitCode}}\n",
+		outStream:   &out,
+		errStream:   &errOut,
+	}
+	ho.printReport(Report{Command: "echo", ExitCode: 1})
+	if out.String() != "" {
+		t.Errorf("stdout should be empty but: %q", out.String())
+	}
+	if errOut.String() != "echo 1\n" {
+		t.Errorf("stderr should be %q but: %q", "echo 1\n", errOut.String())
+	}
+}
+
+func TestRunHandler_format(t *testing.T) {
+	fname := temp()
+	defer os.RemoveAll(fname)
+	ho := &horenso{}
+	h := handler{
+		Command: "go run testdata/reporter.go " + fname,
+		Format:  "{{.Command}} exited with {{.ExitCode}}",
+	}
+	if err := ho.runHandler(h, []byte(`{"command":"echo","exitCode":1}`), phaseReport); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	b, _ := ioutil.ReadFile(fname)
+	if expect := "echo exited with 1"; string(b) != expect {
+		t.Errorf("handler input should be %q but: %q", expect, string(b))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		return
+	}
+	file := os.Args[1]
+	env := map[string]string{}
+	for _, k := range []string{
+		"HORENSO_TAG",
+		"HORENSO_EXIT_CODE",
+		"HORENSO_RESULT",
+		"HORENSO_COMMAND",
+		"HORENSO_PHASE",
+		"HORENSO_START_AT",
+		"HORENSO_DURATION",
+		"HORENSO_REPORT_FILE",
+	} {
+		env[k] = os.Getenv(k)
+	}
+	report, _ := ioutil.ReadFile(env["HORENSO_REPORT_FILE"])
+	env["report"] = string(report)
+	b, _ := json.Marshal(env)
+	ioutil.WriteFile(file, b, os.ModePerm)
+}
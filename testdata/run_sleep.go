@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	d := 10 * time.Second
+	if len(os.Args) > 1 {
+		d, _ = time.ParseDuration(os.Args[1])
+	}
+	fmt.Println("sleeping")
+	time.Sleep(d)
+	fmt.Println("awake")
+}
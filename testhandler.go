@@ -0,0 +1,154 @@
+package horenso
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+const (
+	variantSuccess       = "success"
+	variantFailure       = "failure"
+	variantSignaled      = "signaled"
+	variantFailedToStart = "failed-to-start"
+)
+
+var testVariants = []string{variantSuccess, variantFailure, variantSignaled, variantFailedToStart}
+
+type testHandlerOpts struct {
+	Variant []string `long:"variant" value-name:"variant" choice:"success" choice:"failure" choice:"signaled" choice:"failed-to-start" description:"variant of the synthetic report (default: all variants)"`
+}
+
+// syntheticReport builds the report of the variant which the handlers would receive
+func (ho *horenso) syntheticReport(variant string) Report {
+	const cmd = "/path/to/job"
+	tag := ho.Tag
+	if tag == "" {
+		tag = "horenso-test"
+	}
+	hostname, _ := os.Hostname()
+	endAt := time.Now()
+	startAt := endAt.Add(-3 * time.Second)
+	r := Report{
+		Command:     cmd,
+		CommandArgs: []string{cmd},
+		Tag:         tag,
+		Hostname:    hostname,
+		Pid:         os.Getpid(),
+		StartAt:     &startAt,
+		EndAt:       &endAt,
+	}
+	switch variant {
+	case variantSuccess:
+		r.Stdout = "job finished\n"
+		r.ExitCode = 0
+		r.Result = fmt.Sprintf("command exited with code: %d", r.ExitCode)
+	case variantFailure:
+		r.Stdout = "job started\n"
+		r.Stderr = "something went wrong\n"
+		r.ExitCode = 1
+		r.Result = fmt.Sprintf("command exited with code: %d", r.ExitCode)
+	case variantSignaled:
+		r.Stdout = "job started\n"
+		r.ExitCode = 128 + 15
+		r.Signaled = true
+		r.Result = fmt.Sprintf("command died with signal: %d", r.ExitCode&127)
+	case variantFailedToStart:
+		r.ExitCode = -1
+		r.Pid = 0
+		r.EndAt = nil
+		r.Result = fmt.Sprintf("failed to execute the command: exec: %q: executable file not found in $PATH", cmd)
+	}
+	r.Output = r.Stdout + r.Stderr
+	r.StdoutBytes = int64(len(r.Stdout))
+	r.StderrBytes = int64(len(r.Stderr))
+	ho.resolveStatus(&r)
+	return r
+}
+
+// testHandler runs the handler with the report and returns the output of the handler.
+// Built-in handlers log their responses instead of returning them.
+func (ho *horenso) testHandler(h handler, json []byte, phase string) ([]byte, error) {
+	switch {
+	case isWebhook(h), isSlack(h), isMail(h), isSyslog(h), isJournald(h):
+		return nil, ho.runHandler(h, json, phase)
+	}
+	h, err := h.expand()
+	if err != nil {
+		return nil, err
+	}
+	return ho.execHandler(h, json, phase)
+}
+
+// testHandlers runs every handler with the synthetic reports of the variants and prints the
+// results. It returns the number of the failed handlers.
+func (ho *horenso) testHandlers(variants []string, w io.Writer) int {
+	failed := 0
+	run := func(kind, phase string, hs handlers, r Report) {
+		json, _ := json.Marshal(r)
+		for _, h := range hs {
+			fmt.Fprintf(w, "--- %s %q: ", kind, h.Command)
+			if !h.On.match(r) {
+				fmt.Fprintf(w, "skipped (the condition %q is not satisfied)\n", h.On)
+				continue
+			}
+			out, err := ho.testHandler(h, json, phase)
+			if err != nil {
+				failed++
+				fmt.Fprintf(w, "failed: %s\n", err)
+			} else {
+				fmt.Fprintln(w, "ok")
+			}
+			if out := strings.TrimSpace(string(out)); out != "" {
+				fmt.Fprintln(w, "    "+strings.Replace(out, "\n", "\n    ", -1))
+			}
+		}
+	}
+	for _, v := range variants {
+		r := ho.syntheticReport(v)
+		fmt.Fprintf(w, "=== %s: %s\n", v, r.Result)
+		nr := r
+		if v != variantFailedToStart {
+			nr = noticeReport(r)
+		}
+		run("noticer", phaseNotice, ho.noticers, nr)
+		run("reporter", phaseReport, ho.reporters, r)
+	}
+	return failed
+}
+
+func runTestHandler(args []string) int {
+	ho := &horenso{outStream: os.Stdout, errStream: os.Stderr}
+	opts := &testHandlerOpts{}
+	p := flags.NewParser(ho, flags.Default)
+	p.Usage = "test-handler [OPTIONS] [--variant variant...]"
+	p.AddGroup("Test Handler Options", "", opts)
+	rest, err := p.ParseArgs(args)
+	if err != nil || len(rest) > 0 {
+		if ferr, ok := err.(*flags.Error); !ok || ferr.Type != flags.ErrHelp {
+			p.WriteHelp(ho.errStream)
+		}
+		return 2
+	}
+	ho.setupLog()
+	if err := ho.loadConfig(); err != nil {
+		ho.logf(warn, "failed to load config: %s", err)
+	}
+	if len(ho.noticers) == 0 && len(ho.reporters) == 0 {
+		fmt.Fprintln(ho.errStream, "no handlers are configured")
+		return 1
+	}
+	variants := opts.Variant
+	if len(variants) == 0 {
+		variants = testVariants
+	}
+	if ho.testHandlers(variants, ho.outStream) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package horenso
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTestHandlers(t *testing.T) {
+	fname := temp()
+	defer os.RemoveAll(fname)
+
+	onFailure, _ := parseCondition("failure")
+	ho := &horenso{}
+	ho.reporters = handlers{
+		{Command: "go run testdata/run.go"},
+		{Command: "go run testdata/reporter.go " + fname, On: conditions{onFailure}},
+	}
+	var b bytes.Buffer
+	if failed := ho.testHandlers([]string{variantSuccess, variantFailedToStart}, &b); failed != 0 {
+		t.Errorf("failed should be 0 but: %d", failed)
+	}
+	out := b.String()
+	for _, s := range []string{
+		"=== success: command exited with code: 0\n",
+		"--- reporter \"go run testdata/run.go\": ok\n    1\n    2\n    3\n",
+		"skipped (the condition \"failure\" is not satisfied)\n",
+		"=== failed-to-start: failed to execute the command: exec: \"/path/to/job\"",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output should contain %q but: %s", s, out)
+		}
+	}
+
+	r := parseReport(fname)
+	if r.ExitCode != -1 || r.EndAt != nil || r.Status != "failure" {
+		t.Errorf("the handler should receive the report of failed-to-start but: %#v", r)
+	}
+
+	ho.reporters = handlers{{Command: "go run testdata/not_found.go"}}
+	b.Reset()
+	if failed := ho.testHandlers([]string{variantFailure}, &b); failed != 1 {
+		t.Errorf("failed should be 1 but: %d", failed)
+	}
+	if !strings.Contains(b.String(), "failed: exit status 1") {
+		t.Errorf("output should contain the failure but: %s", b.String())
+	}
+}
+
+func TestSyntheticReport(t *testing.T) {
+	ho := &horenso{Tag: "backup"}
+	ho.loadConfig()
+	r := ho.syntheticReport(variantSignaled)
+	if !r.Signaled || r.ExitCode != 143 || r.Result != "command died with signal: 15" {
+		t.Errorf("invalid signaled report: %#v", r)
+	}
+	if r.Tag != "backup" || r.Status != "failure" {
+		t.Errorf("invalid signaled report: %#v", r)
+	}
+	r = ho.syntheticReport(variantSuccess)
+	if r.ExitCode != 0 || r.Status != "success" || r.Severity != "ok" {
+		t.Errorf("invalid success report: %#v", r)
+	}
+}
@@ -0,0 +1,109 @@
+package horenso
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	webhookScheme           = "webhook+"
+	defaultWebhookTimeout   = 30 * time.Second
+	defaultWebhookSecretEnv = "HORENSO_WEBHOOK_SECRET"
+	signatureHeader         = "X-Horenso-Signature"
+)
+
+func isWebhook(h handler) bool {
+	return strings.HasPrefix(h.Command, webhookScheme)
+}
+
+// webhookSecret reads the secret for signing the request body from the file or
+// the environment variable
+func webhookSecret(h handler) (string, error) {
+	if h.SecretFile != "" {
+		b, err := ioutil.ReadFile(h.SecretFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the secret file: %s", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	env := h.SecretEnv
+	if env == "" {
+		env = defaultWebhookSecretEnv
+	}
+	return os.Getenv(env), nil
+}
+
+func signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// runWebhook posts the report to the URL of the webhook handler
+func (ho *horenso) runWebhook(h handler, body []byte) error {
+	endpoint := strings.TrimPrefix(h.Command, webhookScheme)
+	// the URL before expanding the references is shown in logs not to leak secrets
+	name := strings.TrimPrefix(h.name(), webhookScheme)
+	ho.logf(info, "starting to post the report to the webhook %q", name)
+	secret, err := webhookSecret(h)
+	if err != nil {
+		err = maskURLError(err, name)
+		ho.logf(warn, "failed to post the report to the webhook %q: %s", name, err)
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		err = maskURLError(err, name)
+		ho.logf(warn, "failed to post the report to the webhook %q: %s", name, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("horenso/%s", version))
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	if secret != "" {
+		req.Header.Set(signatureHeader, signature(secret, body))
+	}
+	timeout := ho.handlerTimeout(h)
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	cl := &http.Client{Timeout: timeout}
+	resp, err := cl.Do(req)
+	if err != nil {
+		err = maskURLError(err, name)
+		ho.logf(warn, "failed to post the report to the webhook %q: %s", name, err)
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("unexpected status: %s", resp.Status)
+		logoutput := fmt.Sprintf("failed to post the report to the webhook %q: %s", name, err)
+		ho.log(warn, ho.appendOut(logoutput, string(respBody)))
+		return err
+	}
+	ho.log(info, ho.appendOut(fmt.Sprintf("finished to post the report to the webhook %q", name), string(respBody)))
+	return nil
+}
+
+// maskURLError replaces the URL in the error with the name of the webhook
+func maskURLError(err error, name string) error {
+	if uerr, ok := err.(*url.Error); ok {
+		e := *uerr
+		e.URL = name
+		return &e
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package horenso
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunWebhook(t *testing.T) {
+	secretFile := temp()
+	defer os.RemoveAll(secretFile)
+	ioutil.WriteFile(secretFile, []byte("s3cr3t\n"), 0600)
+
+	body := []byte(`{"command":"echo","exitCode":0}`)
+	var got []byte
+	var gotSig, gotHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ioutil.ReadAll(r.Body)
+		gotSig = r.Header.Get("X-Horenso-Signature")
+		gotHeader = r.Header.Get("X-Custom")
+	}))
+	defer ts.Close()
+
+	ho := &horenso{}
+	h := handler{
+		Command:    "webhook+" + ts.URL,
+		Headers:    map[string]string{"X-Custom": "hoge"},
+		SecretFile: secretFile,
+	}
+	if err := ho.runHandler(h, body, phaseReport); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if string(got) != string(body) {
+		t.Errorf("body should be %s but: %s", body, got)
+	}
+	if expect := signature("s3cr3t", body); gotSig != expect {
+		t.Errorf("signature should be %s but: %s", expect, gotSig)
+	}
+	if gotHeader != "hoge" {
+		t.Errorf("X-Custom header should be hoge but: %s", gotHeader)
+	}
+}
+
+func TestRunWebhook_retry(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	ho := &horenso{}
+	h := handler{
+		Command:       "webhook+" + ts.URL,
+		Retry:         2,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	if err := ho.deliver(h, []byte(`{}`), phaseReport); err != nil {
+		t.Errorf("err should be nil but: %s", err)
+	}
+	if c := atomic.LoadInt32(&count); c != 2 {
+		t.Errorf("the webhook should be requested twice but: %d", c)
+	}
+
+	ts.Close()
+	h.Retry = 0
+	if err := ho.deliver(h, []byte(`{}`), phaseReport); err == nil {
+		t.Errorf("err shouldn't be nil")
+	}
+}